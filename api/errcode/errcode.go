@@ -0,0 +1,122 @@
+package errcode
+
+import (
+	"ZhiShanYunXue/util"
+	"errors"
+	"net/http"
+)
+
+// 业务错误码 返回在 Data.Code 中
+// 通用结果直接使用HTTP状态码 如 200 成功、422 请求参数错误、500 服务器内部错误
+// 具体错误为 HTTP状态码*100+序号 客户端可通过 code/100 得到对应的HTTP状态码
+const (
+	// NotLoggedIn 未携带登录令牌
+	NotLoggedIn = 40101
+	// InvalidToken 登录状态无效或已过期
+	InvalidToken = 40102
+	// InvalidCredentials 账号或密码错误
+	InvalidCredentials = 40103
+	// InvalidActivationCode 激活码错误或账号已激活
+	InvalidActivationCode = 40104
+
+	// Forbidden 没有操作权限
+	Forbidden = 40301
+	// TaskNotAssigned 任务未布置给该学生
+	TaskNotAssigned = 40302
+	// DeadlinePassed 任务已截止且不允许迟交
+	DeadlinePassed = 40303
+	// NoAttemptsLeft 已达到最大提交次数
+	NoAttemptsLeft = 40304
+	// TimeLimitExceeded 答题时间已到
+	TimeLimitExceeded = 40305
+	// AttemptNotStarted 限时任务尚未开始作答
+	AttemptNotStarted = 40306
+	// PasswordChangeRequired 需先修改初始密码
+	PasswordChangeRequired = 40307
+
+	// TaskNotFound 任务不存在
+	TaskNotFound = 40401
+	// QuestionNotFound 题目不存在
+	QuestionNotFound = 40402
+	// ClassNotFound 班级不存在
+	ClassNotFound = 40403
+	// KnowledgePointNotFound 知识点不存在
+	KnowledgePointNotFound = 40404
+	// AnswerNotFound 学生答案不存在
+	AnswerNotFound = 40405
+	// SubmissionNotFound 学生提交记录不存在
+	SubmissionNotFound = 40406
+	// AttemptNotFound 指定的作答不存在
+	AttemptNotFound = 40407
+	// NoWrongQuestions 没有符合条件的错题
+	NoWrongQuestions = 40408
+	// NoQuestions 任务中没有题目
+	NoQuestions = 40409
+
+	// UserExists 账号重复
+	UserExists = 40901
+	// GradingIncomplete 仍有题目未评分
+	GradingIncomplete = 40902
+
+	// InvalidDeadline 截止时间格式错误
+	InvalidDeadline = 42201
+	// InvalidQuestion 题目设置错误
+	InvalidQuestion = 42202
+	// InvalidScore 评分超出本题分值范围
+	InvalidScore = 42203
+	// InvalidImportFile 导入文件格式错误
+	InvalidImportFile = 42204
+	// KnowledgeTooDeep 知识点层级过深
+	KnowledgeTooDeep = 42205
+	// DuplicateAnswer 同一次提交中同一题目出现多次
+	DuplicateAnswer = 42206
+)
+
+// mapping util中的错误与HTTP状态码、业务错误码的对应关系
+var mapping = []struct {
+	err    error
+	status int
+	code   int
+}{
+	{util.ErrInvalidToken, http.StatusUnauthorized, InvalidToken},
+	{util.ErrInvalidCredentials, http.StatusUnauthorized, InvalidCredentials},
+	{util.ErrInvalidActivationCode, http.StatusUnauthorized, InvalidActivationCode},
+
+	{util.ErrForbidden, http.StatusForbidden, Forbidden},
+	{util.ErrTaskNotAssigned, http.StatusForbidden, TaskNotAssigned},
+	{util.ErrDeadlinePassed, http.StatusForbidden, DeadlinePassed},
+	{util.ErrNoAttemptsLeft, http.StatusForbidden, NoAttemptsLeft},
+	{util.ErrTimeLimitExceeded, http.StatusForbidden, TimeLimitExceeded},
+	{util.ErrAttemptNotStarted, http.StatusForbidden, AttemptNotStarted},
+	{util.ErrPasswordChangeRequired, http.StatusForbidden, PasswordChangeRequired},
+
+	{util.ErrTaskNotFound, http.StatusNotFound, TaskNotFound},
+	{util.ErrQuestionNotFound, http.StatusNotFound, QuestionNotFound},
+	{util.ErrClassNotFound, http.StatusNotFound, ClassNotFound},
+	{util.ErrKnowledgePointNotFound, http.StatusNotFound, KnowledgePointNotFound},
+	{util.ErrAnswerNotFound, http.StatusNotFound, AnswerNotFound},
+	{util.ErrSubmissionNotFound, http.StatusNotFound, SubmissionNotFound},
+	{util.ErrAttemptNotFound, http.StatusNotFound, AttemptNotFound},
+	{util.ErrNoWrongQuestions, http.StatusNotFound, NoWrongQuestions},
+	{util.ErrNoQuestions, http.StatusNotFound, NoQuestions},
+
+	{util.ErrUserExists, http.StatusConflict, UserExists},
+	{util.ErrGradingIncomplete, http.StatusConflict, GradingIncomplete},
+
+	{util.ErrInvalidDeadline, http.StatusUnprocessableEntity, InvalidDeadline},
+	{util.ErrInvalidQuestion, http.StatusUnprocessableEntity, InvalidQuestion},
+	{util.ErrInvalidScore, http.StatusUnprocessableEntity, InvalidScore},
+	{util.ErrInvalidImportFile, http.StatusUnprocessableEntity, InvalidImportFile},
+	{util.ErrKnowledgeTooDeep, http.StatusUnprocessableEntity, KnowledgeTooDeep},
+	{util.ErrDuplicateAnswer, http.StatusUnprocessableEntity, DuplicateAnswer},
+}
+
+// From 查找错误对应的HTTP状态码与业务错误码 无法识别的错误返回500且ok为false
+func From(err error) (status int, code int, ok bool) {
+	for _, m := range mapping {
+		if errors.Is(err, m.err) {
+			return m.status, m.code, true
+		}
+	}
+	return http.StatusInternalServerError, http.StatusInternalServerError, false
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"ZhiShanYunXue/api/errcode"
+	"ZhiShanYunXue/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// 上下文中保存的登录信息键名
+const (
+	TokenKey  = "token"
+	UserIdKey = "user_id"
+	RoleKey   = "role"
+)
+
+// getToken 从请求头获取登录令牌 支持 Authorization: Bearer <token> 与 Token: <token>
+func getToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return c.GetHeader("Token")
+}
+
+// Auth 校验登录令牌 并限制允许访问的角色 需修改初始密码的账号不能访问
+func Auth(roles ...string) gin.HandlerFunc {
+	return auth(false, roles)
+}
+
+// PasswordChangeAuth 与Auth相同 但允许需修改初始密码的账号访问 用于修改密码与退出登录
+func PasswordChangeAuth(roles ...string) gin.HandlerFunc {
+	return auth(true, roles)
+}
+
+// auth 校验登录令牌与角色 allowPasswordChange为false时拒绝需修改初始密码的账号
+func auth(allowPasswordChange bool, roles []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger, _ := util.NewLogger()
+
+		token := getToken(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code": errcode.NotLoggedIn,
+				"msg":  "请先登录",
+				"data": nil,
+			})
+			return
+		}
+
+		session, err := util.GetSession(token)
+		if err != nil {
+			if !errors.Is(err, util.ErrInvalidToken) {
+				logger.Error("校验登录令牌失败: ", err)
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"code": errcode.InvalidToken,
+				"msg":  "登录状态无效或已过期",
+				"data": nil,
+			})
+			return
+		}
+
+		allowed := len(roles) == 0
+		for _, role := range roles {
+			if session.Role == role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code": errcode.Forbidden,
+				"msg":  "没有访问权限",
+				"data": nil,
+			})
+			return
+		}
+
+		if session.MustChangePassword && !allowPasswordChange {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code": errcode.PasswordChangeRequired,
+				"msg":  util.ErrPasswordChangeRequired.Error(),
+				"data": nil,
+			})
+			return
+		}
+
+		c.Set(TokenKey, session.Token)
+		c.Set(UserIdKey, session.UserId)
+		c.Set(RoleKey, session.Role)
+		c.Next()
+	}
+}
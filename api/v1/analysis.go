@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"ZhiShanYunXue/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetTaskAnalysisRequest 获取任务项目分析 请求结构体
+type GetTaskAnalysisRequest struct {
+	TaskId string `form:"task_id" binding:"required"`
+}
+
+// GetTaskAnalysis 获取任务每道题的难度、区分度与选项分布
+func GetTaskAnalysis(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req GetTaskAnalysisRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+
+	analysis, err := util.GetTaskAnalysis(req.TaskId)
+	if err != nil {
+		respondError(c, err, "获取项目分析失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: analysis,
+	})
+}
+
+// GetTaskSummaryRequest 获取任务成绩汇总 请求结构体
+type GetTaskSummaryRequest struct {
+	TaskId string `form:"task_id" binding:"required"`
+	// ClassId 只统计该班级的学生
+	ClassId string `form:"class_id"`
+	// Bins 成绩分布直方图的分段数 默认10段
+	Bins int `form:"bins" binding:"omitempty,min=1,max=100"`
+}
+
+// GetTaskSummary 获取任务的成绩分布与提交情况
+func GetTaskSummary(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req GetTaskSummaryRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+	if req.ClassId != "" && !checkClassOwner(c, req.ClassId) {
+		return
+	}
+
+	summary, err := util.GetTaskSummary(req.TaskId, req.ClassId, req.Bins)
+	if err != nil {
+		respondError(c, err, "获取成绩汇总失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: summary,
+	})
+}
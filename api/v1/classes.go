@@ -0,0 +1,240 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/middleware"
+	"ZhiShanYunXue/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// checkClassOwner 检查当前用户是否有权管理班级 无权限时直接写入响应并返回false
+func checkClassOwner(c *gin.Context, classId string) bool {
+	err := util.CheckClassOwner(classId, c.GetString(middleware.UserIdKey), c.GetString(middleware.RoleKey))
+	if err == nil {
+		return true
+	}
+	respondError(c, err, "检查班级权限失败")
+	return false
+}
+
+// checkTaskAssigned 检查任务是否布置给了当前学生 无权限时直接写入响应并返回false
+func checkTaskAssigned(c *gin.Context, taskId string) bool {
+	err := util.CheckTaskAssigned(taskId, c.GetString(middleware.UserIdKey))
+	if err == nil {
+		return true
+	}
+	respondError(c, err, "检查任务权限失败")
+	return false
+}
+
+// NewClassRequest 新建班级 请求结构体
+type NewClassRequest struct {
+	ClassName string `json:"class_name" binding:"required"`
+}
+
+// NewClass 新建班级
+func NewClass(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req NewClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	classInfo, err := util.AddClass(req.ClassName, c.GetString(middleware.UserIdKey))
+	if err != nil {
+		respondError(c, err, "新建班级失败")
+		return
+	}
+	c.JSON(http.StatusCreated, Data{
+		Code: http.StatusCreated,
+		Msg:  "新建班级成功",
+		Data: classInfo,
+	})
+}
+
+// ListClasses 获取班级列表
+func ListClasses(c *gin.Context) {
+	// 教师只能看到自己的班级 管理员可以看到全部班级
+	ownerId := ""
+	if c.GetString(middleware.RoleKey) != util.RoleAdmin {
+		ownerId = c.GetString(middleware.UserIdKey)
+	}
+	classes, err := util.ListClasses(ownerId)
+	if err != nil {
+		respondError(c, err, "获取班级列表失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: classes,
+	})
+}
+
+// UpdateClassRequest 修改班级 请求结构体
+type UpdateClassRequest struct {
+	ClassId   string `json:"class_id" binding:"required"`
+	ClassName string `json:"class_name" binding:"required"`
+}
+
+// UpdateClass 修改班级
+func UpdateClass(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req UpdateClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkClassOwner(c, req.ClassId) {
+		return
+	}
+
+	if err := util.UpdateClass(req.ClassId, req.ClassName); err != nil {
+		respondError(c, err, "修改班级失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "修改班级成功",
+	})
+}
+
+// ClassIdRequest 仅包含班级ID的 请求结构体
+type ClassIdRequest struct {
+	ClassId string `json:"class_id" form:"class_id" binding:"required"`
+}
+
+// DeleteClass 删除班级
+func DeleteClass(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ClassIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkClassOwner(c, req.ClassId) {
+		return
+	}
+
+	if err := util.DeleteClass(req.ClassId); err != nil {
+		respondError(c, err, "删除班级失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "删除班级成功",
+	})
+}
+
+// ClassMembersRequest 添加或移除班级成员 请求结构体
+type ClassMembersRequest struct {
+	ClassId    string   `json:"class_id" binding:"required"`
+	StudentIds []string `json:"student_ids" binding:"required,min=1,dive,required"`
+}
+
+// AddClassMembers 添加班级成员
+func AddClassMembers(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ClassMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkClassOwner(c, req.ClassId) {
+		return
+	}
+
+	added, err := util.AddClassMembers(req.ClassId, req.StudentIds)
+	if err != nil {
+		respondError(c, err, "添加班级成员失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "添加班级成员成功",
+		Data: gin.H{"added": added},
+	})
+}
+
+// RemoveClassMembers 移除班级成员
+func RemoveClassMembers(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ClassMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkClassOwner(c, req.ClassId) {
+		return
+	}
+
+	removed, err := util.RemoveClassMembers(req.ClassId, req.StudentIds)
+	if err != nil {
+		respondError(c, err, "移除班级成员失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "移除班级成员成功",
+		Data: gin.H{"removed": removed},
+	})
+}
+
+// GetClassMembers 获取班级成员
+func GetClassMembers(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ClassIdRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkClassOwner(c, req.ClassId) {
+		return
+	}
+
+	members, err := util.GetClassMembers(req.ClassId)
+	if err != nil {
+		respondError(c, err, "获取班级成员失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: members,
+	})
+}
@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"ZhiShanYunXue/util"
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+)
+
+// ExportResultsRequest 导出任务成绩 请求结构体
+type ExportResultsRequest struct {
+	TaskId string `form:"task_id" binding:"required"`
+	// Format 导出格式 csv或xlsx 默认csv
+	Format string `form:"format" binding:"omitempty,oneof=csv xlsx"`
+	// ClassId 只导出该班级的学生
+	ClassId string `form:"class_id"`
+}
+
+// ExportResults 导出任务成绩表 每个学生一行 每道题一列
+func ExportResults(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ExportResultsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+	if req.ClassId != "" && !checkClassOwner(c, req.ClassId) {
+		return
+	}
+
+	table, err := util.GetResultTable(req.TaskId, req.ClassId)
+	if err != nil {
+		respondError(c, err, "导出成绩失败")
+		return
+	}
+
+	// 先写入缓冲区 出错时仍可返回JSON错误
+	var buf bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+	if req.Format == util.ExportXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = table.WriteXLSX(&buf)
+	} else {
+		req.Format = util.ExportCSV
+		err = table.WriteCSV(&buf)
+	}
+	if err != nil {
+		respondError(c, err, "导出成绩失败")
+		return
+	}
+
+	filename := table.TaskTitle + "." + req.Format
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(filename))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
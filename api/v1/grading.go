@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/middleware"
+	"ZhiShanYunXue/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetGradingQueueRequest 获取评分队列 请求结构体
+type GetGradingQueueRequest struct {
+	TaskId string `form:"task_id" binding:"required"`
+	// QaId 只获取某一题的待评答案
+	QaId string `form:"qa_id"`
+	// IncludeGraded 是否包括已人工评分的答案
+	IncludeGraded bool `form:"include_graded"`
+}
+
+// GetGradingQueue 获取任务中等待人工评分的答案
+func GetGradingQueue(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req GetGradingQueueRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+
+	items, err := util.ListGradingQueue(req.TaskId, req.QaId, req.IncludeGraded)
+	if err != nil {
+		respondError(c, err, "获取评分队列失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: items,
+	})
+}
+
+// GradeAnswerRequest 人工评分 请求结构体
+type GradeAnswerRequest struct {
+	TaskId    string   `json:"task_id" binding:"required"`
+	StudentId string   `json:"student_id" binding:"required"`
+	QaId      string   `json:"qa_id" binding:"required"`
+	Score     *float64 `json:"score" binding:"required"`
+	Comment   string   `json:"comment"`
+	// Attempt 评分的作答 为空时为该学生最近一次作答
+	Attempt int `json:"attempt" binding:"omitempty,min=1"`
+}
+
+// GradeAnswer 教师为学生的单题答案评分并填写评语
+func GradeAnswer(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req GradeAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+
+	taskScore, err := util.GradeAnswer(req.TaskId, req.StudentId, req.QaId, req.Attempt, *req.Score, req.Comment, c.GetString(middleware.UserIdKey))
+	if err != nil {
+		respondError(c, err, "评分失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "评分成功",
+		Data: taskScore,
+	})
+}
+
+// FinishGradingRequest 完成评分 请求结构体
+type FinishGradingRequest struct {
+	TaskId    string `json:"task_id" binding:"required"`
+	StudentId string `json:"student_id" binding:"required"`
+}
+
+// FinishGrading 标记学生的提交已完成评分
+func FinishGrading(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req FinishGradingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+
+	if err := util.FinishGrading(req.TaskId, req.StudentId); err != nil {
+		respondError(c, err, "完成评分失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "完成评分成功",
+	})
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/middleware"
+	"ZhiShanYunXue/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// NewKnowledgePointRequest 添加知识点 请求结构体
+type NewKnowledgePointRequest struct {
+	Name string `json:"name" binding:"required"`
+	// ParentId 上级章或节 为空时添加章
+	ParentId string `json:"parent_id"`
+}
+
+// NewKnowledgePoint 添加知识点
+func NewKnowledgePoint(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req NewKnowledgePointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	node, err := util.AddKnowledgePoint(req.Name, req.ParentId)
+	if err != nil {
+		respondError(c, err, "添加知识点失败")
+		return
+	}
+	c.JSON(http.StatusCreated, Data{
+		Code: http.StatusCreated,
+		Msg:  "添加知识点成功",
+		Data: node,
+	})
+}
+
+// GetKnowledgeTree 获取知识点目录树
+func GetKnowledgeTree(c *gin.Context) {
+	tree, err := util.GetKnowledgeTree()
+	if err != nil {
+		respondError(c, err, "获取知识点目录失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: tree,
+	})
+}
+
+// SetQuestionKnowledgePointsRequest 设置题目知识点 请求结构体
+type SetQuestionKnowledgePointsRequest struct {
+	QaId  string   `json:"qa_id" binding:"required"`
+	KpIds []string `json:"kp_ids"`
+}
+
+// SetQuestionKnowledgePoints 替换题目关联的知识点
+func SetQuestionKnowledgePoints(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req SetQuestionKnowledgePointsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkQuestionOwner(c, req.QaId) {
+		return
+	}
+
+	if err := util.SetQuestionKnowledgePoints(req.QaId, req.KpIds); err != nil {
+		respondError(c, err, "设置题目知识点失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "设置题目知识点成功",
+	})
+}
+
+// GetMasteryRequest 获取知识点掌握情况 请求结构体
+type GetMasteryRequest struct {
+	// StudentId 教师查询时必填 学生只能查询自己
+	StudentId string `form:"student_id"`
+	// Start、End 按提交时间筛选 例如一个学期
+	Start string `form:"start"`
+	End   string `form:"end"`
+}
+
+// GetMastery 获取学生在所有任务中按知识点汇总的掌握情况
+func GetMastery(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req GetMasteryRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	if c.GetString(middleware.RoleKey) == util.RoleStudent {
+		req.StudentId = c.GetString(middleware.UserIdKey)
+	}
+	if req.StudentId == "" {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	mastery, err := util.GetStudentMastery(req.StudentId, req.Start, req.End)
+	if err != nil {
+		respondError(c, err, "获取知识点掌握情况失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: mastery,
+	})
+}
@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/middleware"
+	"ZhiShanYunXue/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// checkQuestionOwner 检查当前用户是否有权管理题目 无权限时直接写入响应并返回false
+func checkQuestionOwner(c *gin.Context, qaId string) bool {
+	err := util.CheckQuestionOwner(qaId, c.GetString(middleware.UserIdKey), c.GetString(middleware.RoleKey))
+	if err == nil {
+		return true
+	}
+	respondError(c, err, "检查题目权限失败")
+	return false
+}
+
+// NewQuestionRequest 向题库添加题目 请求结构体
+// 题库题目没有题号 在任务中选用时重新编号
+type NewQuestionRequest struct {
+	QaTitle         string   `json:"qa_title" binding:"required"`
+	QaAnswer        string   `json:"qa_answer" binding:"required"`
+	QaType          string   `json:"qa_type"`
+	Options         []string `json:"options"`
+	OptionCount     int      `json:"option_count"`
+	AcceptedAnswers []string `json:"accepted_answers"`
+	Tolerance       float64  `json:"tolerance"`
+	Points          float64  `json:"points"`
+	PartialCredit   string   `json:"partial_credit"`
+	Tags            []string `json:"tags"`
+	KpIds           []string `json:"kp_ids"`
+}
+
+// NewQuestion 向题库添加题目
+func NewQuestion(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req NewQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	answer := util.QAAnswer{
+		QaTitle:         req.QaTitle,
+		QaAnswer:        req.QaAnswer,
+		QaType:          req.QaType,
+		Options:         req.Options,
+		OptionCount:     req.OptionCount,
+		AcceptedAnswers: req.AcceptedAnswers,
+		Tolerance:       req.Tolerance,
+		Points:          req.Points,
+		PartialCredit:   req.PartialCredit,
+		KpIds:           req.KpIds,
+	}
+	question, err := util.AddBankQuestion(c.GetString(middleware.UserIdKey), answer, req.Tags)
+	if err != nil {
+		respondError(c, err, "添加题目失败")
+		return
+	}
+	c.JSON(http.StatusCreated, Data{
+		Code: http.StatusCreated,
+		Msg:  "添加题目成功",
+		Data: question,
+	})
+}
+
+// SearchQuestionsRequest 搜索题库 请求结构体
+type SearchQuestionsRequest struct {
+	Keyword  string `form:"keyword"`
+	Tag      string `form:"tag"`
+	QaType   string `form:"q_type"`
+	Mine     bool   `form:"mine"`
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+}
+
+// SearchQuestions 搜索题库 题库对所有教师共享 mine为true时只返回自己创建的题目
+func SearchQuestions(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req SearchQuestionsRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	query := util.BankQuery{
+		Keyword:  req.Keyword,
+		Tag:      req.Tag,
+		QaType:   req.QaType,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}
+	if req.Mine {
+		query.OwnerId = c.GetString(middleware.UserIdKey)
+	}
+	list, err := util.SearchBankQuestions(query)
+	if err != nil {
+		respondError(c, err, "搜索题库失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: list,
+	})
+}
+
+// SetQuestionTagsRequest 设置题目标签 请求结构体
+type SetQuestionTagsRequest struct {
+	QaId string   `json:"qa_id" binding:"required"`
+	Tags []string `json:"tags"`
+}
+
+// SetQuestionTags 替换题目的标签
+func SetQuestionTags(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req SetQuestionTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkQuestionOwner(c, req.QaId) {
+		return
+	}
+
+	if err := util.SetQuestionTags(req.QaId, req.Tags); err != nil {
+		respondError(c, err, "设置题目标签失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "设置题目标签成功",
+	})
+}
+
+// QaIdRequest 仅包含题目ID的 请求结构体
+type QaIdRequest struct {
+	QaId string `json:"qa_id" form:"qa_id" binding:"required"`
+}
+
+// GetQuestionUsage 获取题目在各任务中的使用统计
+func GetQuestionUsage(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req QaIdRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkQuestionOwner(c, req.QaId) {
+		return
+	}
+
+	usage, err := util.GetQuestionUsage(req.QaId)
+	if err != nil {
+		respondError(c, err, "获取题目使用统计失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: usage,
+	})
+}
@@ -1,8 +1,34 @@
 package v1
 
+import (
+	"ZhiShanYunXue/api/errcode"
+	"ZhiShanYunXue/util"
+	"github.com/gin-gonic/gin"
+)
+
 // Data API的标准返回结构体
+// Code 为业务错误码 通用结果与HTTP状态码相同 具体错误见errcode包
 type Data struct {
 	Code int         `json:"code"`
 	Msg  string      `json:"msg"`
 	Data interface{} `json:"data"`
 }
+
+// respondError 按errcode的对应关系写入HTTP状态码、业务错误码与错误信息
+// 无法识别的错误视为服务器内部错误 记录日志并返回msg
+func respondError(c *gin.Context, err error, msg string) {
+	status, code, ok := errcode.From(err)
+	if !ok {
+		logger, _ := util.NewLogger()
+		logger.Errorf("%s: %v", msg, err)
+		c.JSON(status, Data{
+			Code: code,
+			Msg:  msg,
+		})
+		return
+	}
+	c.JSON(status, Data{
+		Code: code,
+		Msg:  err.Error(),
+	})
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/errcode"
+	"ZhiShanYunXue/api/middleware"
+	"ZhiShanYunXue/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RegisterStudentRequest 教师创建学生账号 请求结构体
+type RegisterStudentRequest struct {
+	StudentId   string `json:"student_id" binding:"required"`
+	StudentName string `json:"student_name" binding:"required"`
+}
+
+// RegisterStudent 教师创建学生账号 返回交给学生的一次性激活码
+func RegisterStudent(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req RegisterStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	activationCode, err := util.RegisterStudent(req.StudentId, req.StudentName)
+	if err != nil {
+		if errors.Is(err, util.ErrUserExists) {
+			c.JSON(http.StatusConflict, Data{
+				Code: errcode.UserExists,
+				Msg:  "学号已注册",
+			})
+			return
+		}
+		respondError(c, err, "注册失败")
+		return
+	}
+	c.JSON(http.StatusCreated, Data{
+		Code: http.StatusCreated,
+		Msg:  "注册成功",
+		Data: gin.H{"student_id": req.StudentId, "activation_code": activationCode},
+	})
+}
+
+// ActivateStudentRequest 学生激活账号 请求结构体
+type ActivateStudentRequest struct {
+	StudentId      string `json:"student_id" binding:"required"`
+	ActivationCode string `json:"activation_code" binding:"required"`
+	Password       string `json:"password" binding:"required,min=6"`
+}
+
+// ActivateStudent 学生凭激活码设置密码
+func ActivateStudent(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ActivateStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	if err := util.ActivateStudent(req.StudentId, req.ActivationCode, req.Password); err != nil {
+		respondError(c, err, "激活失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "激活成功",
+	})
+}
+
+// LoginStudentRequest 学生登录 请求结构体
+type LoginStudentRequest struct {
+	StudentId string `json:"student_id" binding:"required"`
+	Password  string `json:"password" binding:"required"`
+}
+
+// LoginStudent 学生登录
+func LoginStudent(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req LoginStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	session, err := util.LoginStudent(req.StudentId, req.Password)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, Data{
+				Code: errcode.InvalidCredentials,
+				Msg:  "学号或密码错误",
+			})
+			return
+		}
+		respondError(c, err, "登录失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "登录成功",
+		Data: session,
+	})
+}
+
+// Logout 退出登录
+func Logout(c *gin.Context) {
+	if err := util.DeleteSession(c.GetString(middleware.TokenKey)); err != nil {
+		respondError(c, err, "退出登录失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "退出登录成功",
+	})
+}
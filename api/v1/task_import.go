@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/errcode"
+	"ZhiShanYunXue/util"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// ImportTask 上传CSV或XLSX答案表新建任务 任务设置与新建任务相同 以表单字段提交
+func ImportTask(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req NewTaskRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请上传答案表文件",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondError(c, err, "读取上传文件失败")
+		return
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}()
+
+	answers, rowErrors, err := util.ParseAnswerSheet(fileHeader.Filename, file)
+	if err != nil {
+		respondError(c, err, "解析答案表失败")
+		return
+	}
+	// 逐行错误与文件无法解析使用同一业务错误码 错误明细在data中返回
+	if len(rowErrors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: errcode.InvalidImportFile,
+			Msg:  "答案表存在错误",
+			Data: rowErrors,
+		})
+		return
+	}
+
+	// 表单中未选择班级时可能提交空值
+	classIds := []string{}
+	for _, classId := range req.ClassIds {
+		if classId != "" {
+			classIds = append(classIds, classId)
+		}
+	}
+	req.ClassIds = classIds
+	req.Answers = answers
+	createTask(c, req)
+}
@@ -1,8 +1,11 @@
 package v1
 
 import (
+	"ZhiShanYunXue/api/errcode"
+	"ZhiShanYunXue/api/middleware"
 	"ZhiShanYunXue/setting"
 	"ZhiShanYunXue/util"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"time"
@@ -10,10 +13,21 @@ import (
 
 // NewTaskRequest 新建任务 请求结构体
 type NewTaskRequest struct {
-	TaskTitle       string          `json:"task_title" binding:"required"`
-	TaskDescription string          `json:"task_description" binding:"required"`
-	Deadline        string          `json:"deadline" binding:"required"`
-	Answers         []util.QAAnswer `json:"answers"`
+	TaskTitle       string          `json:"task_title" form:"task_title" binding:"required"`
+	TaskDescription string          `json:"task_description" form:"task_description" binding:"required"`
+	Deadline        string          `json:"deadline" form:"deadline" binding:"required"`
+	AllowLate       bool            `json:"allow_late" form:"allow_late"`
+	AnswerRelease   string          `json:"answer_release" form:"answer_release" binding:"omitempty,oneof=never after_submit after_deadline manual"`
+	ClassIds        []string        `json:"class_ids" form:"class_ids"`
+	Answers         []util.QAAnswer `json:"answers" form:"-"`
+	// QaIds 从题库选用的题目 题号接在answers之后
+	QaIds []string `json:"qa_ids" form:"qa_ids"`
+	// MaxAttempts 最大提交次数 默认1次 0表示不限
+	MaxAttempts *int `json:"max_attempts" form:"max_attempts" binding:"omitempty,min=0"`
+	// AttemptPolicy 多次提交的计分方式 highest、last或average 默认取最高分
+	AttemptPolicy string `json:"attempt_policy" form:"attempt_policy" binding:"omitempty,oneof=highest last average"`
+	// TimeLimit 每次作答的时限(秒) 从获取任务时开始计时 0表示不限时
+	TimeLimit int `json:"time_limit" form:"time_limit" binding:"omitempty,min=0"`
 }
 
 // NewTask 新建任务
@@ -30,17 +44,46 @@ func NewTask(c *gin.Context) {
 		return
 	}
 	logger.Info("验证数据成功")
+	createTask(c, req)
+}
+
+// createTask 校验并创建任务 新建任务与导入任务共用
+func createTask(c *gin.Context, req NewTaskRequest) {
+	logger, _ := util.NewLogger()
+	// 校验截止时间
+	deadline, err := util.NormalizeDeadline(req.Deadline)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "截止时间格式错误",
+		})
+		return
+	}
+	// 只能布置到自己管理的班级
+	for _, classId := range req.ClassIds {
+		if !checkClassOwner(c, classId) {
+			return
+		}
+	}
 	// 在服务端生成任务Id
 	taskId := util.GenerateTaskId(setting.MaxTries)
 
 	logger.Info(req.Answers)
 	// 操作数据库 - 添加任务
-	_, err := util.AddTask(taskId, req.TaskTitle, req.TaskDescription, req.Deadline, req.Answers)
+	taskSetting := util.TaskSetting{
+		AllowLate:     req.AllowLate,
+		AnswerRelease: req.AnswerRelease,
+		ClassIds:      req.ClassIds,
+		MaxAttempts:   1,
+		AttemptPolicy: req.AttemptPolicy,
+		TimeLimit:     req.TimeLimit,
+	}
+	if req.MaxAttempts != nil {
+		taskSetting.MaxAttempts = *req.MaxAttempts
+	}
+	_, err = util.AddTask(taskId, c.GetString(middleware.UserIdKey), req.TaskTitle, req.TaskDescription, deadline, taskSetting, req.Answers, req.QaIds)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "生成任务失败",
-		})
+		respondError(c, err, "生成任务失败")
 		return
 	}
 	c.JSON(http.StatusCreated, Data{
@@ -48,7 +91,6 @@ func NewTask(c *gin.Context) {
 		Msg:  "生成任务成功！",
 		Data: gin.H{"task_id": taskId},
 	})
-	return
 }
 
 // GetInfoRequest 获取任务信息 请求结构体
@@ -70,13 +112,18 @@ func GetInfo(c *gin.Context) {
 		return
 	}
 	logger.Info("验证数据成功")
+	// 学生只能查看布置给自己的任务 教师只能查看自己的任务
+	if c.GetString(middleware.RoleKey) == util.RoleStudent {
+		if !checkTaskAssigned(c, req.TaskID) {
+			return
+		}
+	} else if !checkTaskOwner(c, req.TaskID) {
+		return
+	}
 	// 操作数据库
 	answersInfo, err := util.GetInfo(req.TaskID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "获取任务失败",
-		})
+		respondError(c, err, "获取任务失败")
 		return
 	}
 	c.JSON(http.StatusOK, Data{
@@ -87,8 +134,7 @@ func GetInfo(c *gin.Context) {
 
 // GetTaskDataRequest 获取任务列表 请求结构体
 type GetTaskDataRequest struct {
-	StudentId string `form:"student_id" binding:"required"`
-	TaskId    string `form:"task_id" binding:"required"`
+	TaskId string `form:"task_id" binding:"required"`
 }
 
 // GetTaskData 获取任务数据
@@ -105,38 +151,95 @@ func GetTaskData(c *gin.Context) {
 		return
 	}
 	logger.Info("验证数据成功")
+	if !checkTaskAssigned(c, req.TaskId) {
+		return
+	}
 	// 操作数据库
 	taskData, err := util.GetTaskData(req.TaskId)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "获取任务失败",
-		})
+		respondError(c, err, "获取任务失败")
 		return
 	}
 
 	// 写入获取任务的时间
-	_, err = util.MarkGetTaskTime(req.StudentId, req.TaskId)
+	_, err = util.MarkGetTaskTime(c.GetString(middleware.UserIdKey), req.TaskId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "写入开始时间失败",
-		})
+		respondError(c, err, "写入开始时间失败")
 		return
 	}
 
+	// 返回已保存的草稿
+	drafts, err := util.GetDraft(c.GetString(middleware.UserIdKey), req.TaskId)
+	if err != nil {
+		respondError(c, err, "获取草稿失败")
+		return
+	}
+	// 限时任务返回剩余时间
+	taskInfo, err := util.GetInfo(req.TaskId)
+	if err != nil {
+		respondError(c, err, "获取任务信息失败")
+		return
+	}
+	remainingTime, err := util.GetRemainingTime(c.GetString(middleware.UserIdKey), req.TaskId)
+	if err != nil {
+		respondError(c, err, "获取剩余时间失败")
+		return
+	}
+	for i := range *taskData {
+		(*taskData)[i].Draft = drafts[(*taskData)[i].QaId].QAnswer
+		(*taskData)[i].TimeLimit = taskInfo.TimeLimit
+		(*taskData)[i].RemainingTime = remainingTime
+	}
+
 	c.JSON(http.StatusOK, Data{
 		Code: http.StatusOK,
 		Data: taskData,
 	})
 }
 
+// GetRemainingTime 获取限时任务本次作答的剩余时间 不限时或没有进行中的作答时remaining_time为null
+func GetRemainingTime(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req GetTaskDataRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskAssigned(c, req.TaskId) {
+		return
+	}
+
+	taskInfo, err := util.GetInfo(req.TaskId)
+	if err != nil {
+		respondError(c, err, "获取任务信息失败")
+		return
+	}
+	remainingTime, err := util.GetRemainingTime(c.GetString(middleware.UserIdKey), req.TaskId)
+	if err != nil {
+		respondError(c, err, "获取剩余时间失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: gin.H{
+			"time_limit":     taskInfo.TimeLimit,
+			"remaining_time": remainingTime,
+		},
+	})
+}
+
 // PushAnswerRequest 提交答案 请求结构体
 type PushAnswerRequest struct {
-	StudentId string              `json:"student_id" binding:"required"`
-	TaskId    string              `json:"task_id" binding:"required"`
-	TaskData  *[]util.StuTaskData `json:"task_data" binding:"required"`
+	TaskId   string              `json:"task_id" binding:"required"`
+	TaskData *[]util.StuTaskData `json:"task_data" binding:"required"`
 }
 
 // PushAnswer 提交答案
@@ -153,45 +256,97 @@ func PushAnswer(c *gin.Context) {
 		return
 	}
 	logger.Info("验证数据成功")
+	if !checkTaskAssigned(c, req.TaskId) {
+		return
+	}
 
-	// 写入数据库
-	_, err := util.PushTaskData(req.StudentId, req.TaskId, req.TaskData)
+	// 检查截止时间
+	isLate, err := util.CheckSubmitDeadline(req.TaskId)
 	if err != nil {
-		if err.Error() == "UNIQUE constraint failed: student_task_answers.student_id, student_task_answers.task_id, student_task_answers.qa_id" {
-			c.JSON(http.StatusInternalServerError, Data{
-				Code: http.StatusInternalServerError,
-				Msg:  "禁止重复提交",
+		if errors.Is(err, util.ErrDeadlinePassed) {
+			c.JSON(http.StatusForbidden, Data{
+				Code: errcode.DeadlinePassed,
+				Msg:  "任务已截止，禁止提交",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "提交答案失败",
-		})
+		respondError(c, err, "提交答案失败")
 		return
 	}
 
-	// 写入答题时间
-	_, err = util.PushAnswerTime(req.StudentId, req.TaskId, time.Now().Format("2006-01-02 15:04:05.000"))
+	// 写入数据库 答案、答题时间与评分在同一事务中完成
+	taskScore, err := util.PushTaskData(c.GetString(middleware.UserIdKey), req.TaskId, req.TaskData, time.Now().Format("2006-01-02 15:04:05.000"), isLate)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "写入答题时间失败",
-		})
+		respondError(c, err, "提交答案失败")
 		return
 	}
+	// 评分未完成时不返回得分 与报告保持一致
+	if !taskScore.GradingComplete {
+		taskScore.Score = 0
+	}
 
 	c.JSON(http.StatusOK, Data{
 		Code: http.StatusOK,
 		Msg:  "提交答案成功",
+		Data: taskScore,
 	})
 	return
 }
 
+// SaveDraftRequest 保存草稿 请求结构体
+type SaveDraftRequest struct {
+	TaskId   string             `json:"task_id" binding:"required"`
+	TaskData []util.StuTaskData `json:"task_data" binding:"required"`
+}
+
+// SaveDraft 保存尚未提交的答案 提交答案时草稿一并提交
+func SaveDraft(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req SaveDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskAssigned(c, req.TaskId) {
+		return
+	}
+
+	// 已截止且不允许迟交的任务不能再保存草稿
+	if _, err := util.CheckSubmitDeadline(req.TaskId); err != nil {
+		if errors.Is(err, util.ErrDeadlinePassed) {
+			c.JSON(http.StatusForbidden, Data{
+				Code: errcode.DeadlinePassed,
+				Msg:  "任务已截止，禁止保存",
+			})
+			return
+		}
+		respondError(c, err, "保存草稿失败")
+		return
+	}
+
+	saveTime, err := util.SaveDraft(c.GetString(middleware.UserIdKey), req.TaskId, req.TaskData)
+	if err != nil {
+		respondError(c, err, "保存草稿失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "保存草稿成功",
+		Data: gin.H{"save_time": saveTime},
+	})
+}
+
 // GetReportRequest 获取报告 请求结构体
 type GetReportRequest struct {
-	StudentId string `form:"student_id" binding:"required"`
-	TaskId    string `form:"task_id" binding:"required"`
+	TaskId string `form:"task_id" binding:"required"`
+	// Attempt 查看第几次作答 为空时查看计入成绩的作答
+	Attempt int `form:"attempt" binding:"omitempty,min=1"`
 }
 
 // GetReport 获取报告
@@ -209,14 +364,14 @@ func GetReport(c *gin.Context) {
 		return
 	}
 	logger.Info("验证数据成功")
+	if !checkTaskAssigned(c, req.TaskId) {
+		return
+	}
 
 	// 从数据获取数据
-	reportData, err := util.GetReportData(req.StudentId, req.TaskId)
-	if err != nil || reportData.TaskData == nil {
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "获取报告失败",
-		})
+	reportData, err := util.GetReportData(c.GetString(middleware.UserIdKey), req.TaskId, req.Attempt)
+	if err != nil {
+		respondError(c, err, "获取报告失败")
 		return
 	}
 	c.JSON(http.StatusOK, Data{
@@ -244,12 +399,12 @@ func GetStatusReportData(c *gin.Context) {
 		return
 	}
 	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
 	reportData, err := util.GetStatusReportData(req.TaskId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Data{
-			Code: http.StatusInternalServerError,
-			Msg:  "获取报告失败",
-		})
+		respondError(c, err, "获取报告失败")
 		return
 	}
 	c.JSON(http.StatusOK, Data{
@@ -257,3 +412,221 @@ func GetStatusReportData(c *gin.Context) {
 		Data: reportData,
 	})
 }
+
+// ListTasksRequest 获取任务列表 请求结构体
+type ListTasksRequest struct {
+	Page          int    `form:"page"`
+	PageSize      int    `form:"page_size"`
+	Keyword       string `form:"keyword"`
+	PublishStart  string `form:"publish_start"`
+	PublishEnd    string `form:"publish_end"`
+	DeadlineStart string `form:"deadline_start"`
+	DeadlineEnd   string `form:"deadline_end"`
+	SortBy        string `form:"sort_by" binding:"omitempty,oneof=publish_time deadline task_title question_count submitted_count"`
+	Order         string `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// ListTasks 获取任务列表
+func ListTasks(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ListTasksRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	// 教师只能看到自己的任务 管理员可以看到全部任务
+	ownerId := ""
+	if c.GetString(middleware.RoleKey) != util.RoleAdmin {
+		ownerId = c.GetString(middleware.UserIdKey)
+	}
+	taskList, err := util.ListTasks(util.TaskListQuery{
+		OwnerId:       ownerId,
+		Page:          req.Page,
+		PageSize:      req.PageSize,
+		Keyword:       req.Keyword,
+		PublishStart:  req.PublishStart,
+		PublishEnd:    req.PublishEnd,
+		DeadlineStart: req.DeadlineStart,
+		DeadlineEnd:   req.DeadlineEnd,
+		SortBy:        req.SortBy,
+		Order:         req.Order,
+	})
+	if err != nil {
+		respondError(c, err, "获取任务列表失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: taskList,
+	})
+}
+
+// UpdateTaskRequest 修改任务 请求结构体
+type UpdateTaskRequest struct {
+	TaskId          string          `json:"task_id" binding:"required"`
+	TaskTitle       *string         `json:"task_title"`
+	TaskDescription *string         `json:"task_description"`
+	Deadline        *string         `json:"deadline"`
+	AllowLate       *bool           `json:"allow_late"`
+	AnswerRelease   *string         `json:"answer_release" binding:"omitempty,oneof=never after_submit after_deadline manual"`
+	ClassIds        *[]string       `json:"class_ids"`
+	MaxAttempts     *int            `json:"max_attempts" binding:"omitempty,min=0"`
+	AttemptPolicy   *string         `json:"attempt_policy" binding:"omitempty,oneof=highest last average"`
+	TimeLimit       *int            `json:"time_limit" binding:"omitempty,min=0"`
+	Answers         []util.QAAnswer `json:"answers"`
+}
+
+// UpdateTask 修改任务
+func UpdateTask(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req UpdateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+	if req.ClassIds != nil {
+		for _, classId := range *req.ClassIds {
+			if !checkClassOwner(c, classId) {
+				return
+			}
+		}
+	}
+
+	regraded, err := util.UpdateTask(req.TaskId, util.TaskUpdate{
+		TaskTitle:       req.TaskTitle,
+		TaskDescription: req.TaskDescription,
+		Deadline:        req.Deadline,
+		AllowLate:       req.AllowLate,
+		AnswerRelease:   req.AnswerRelease,
+		ClassIds:        req.ClassIds,
+		MaxAttempts:     req.MaxAttempts,
+		AttemptPolicy:   req.AttemptPolicy,
+		TimeLimit:       req.TimeLimit,
+		Answers:         req.Answers,
+	})
+	if err != nil {
+		respondError(c, err, "修改任务失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "修改任务成功",
+		Data: gin.H{"regraded": regraded},
+	})
+}
+
+// TaskIdRequest 仅包含任务ID的 请求结构体
+type TaskIdRequest struct {
+	TaskId string `json:"task_id" binding:"required"`
+}
+
+// CloseTask 关闭任务
+func CloseTask(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req TaskIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+
+	if err := util.CloseTask(req.TaskId); err != nil {
+		respondError(c, err, "关闭任务失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "关闭任务成功",
+	})
+}
+
+// DeleteTask 删除任务
+func DeleteTask(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req TaskIdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+
+	if err := util.DeleteTask(req.TaskId); err != nil {
+		respondError(c, err, "删除任务失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "删除任务成功",
+	})
+}
+
+// ReleaseAnswerRequest 公布答案 请求结构体
+type ReleaseAnswerRequest struct {
+	TaskId string `json:"task_id" binding:"required"`
+	// Released 为false时撤回已公布的答案 默认公布
+	Released *bool `json:"released"`
+}
+
+// ReleaseAnswer 手动公布答案 用于公布策略为manual的任务
+func ReleaseAnswer(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ReleaseAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if !checkTaskOwner(c, req.TaskId) {
+		return
+	}
+
+	released := req.Released == nil || *req.Released
+	if err := util.SetAnswerReleased(req.TaskId, released); err != nil {
+		respondError(c, err, "公布答案失败")
+		return
+	}
+	msg := "公布答案成功"
+	if !released {
+		msg = "撤回答案成功"
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  msg,
+	})
+}
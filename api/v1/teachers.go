@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/errcode"
+	"ZhiShanYunXue/api/middleware"
+	"ZhiShanYunXue/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// LoginTeacherRequest 教师登录 请求结构体
+type LoginTeacherRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// LoginTeacher 教师登录
+func LoginTeacher(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req LoginTeacherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	session, err := util.LoginTeacher(req.Username, req.Password)
+	if err != nil {
+		respondError(c, err, "登录失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "登录成功",
+		Data: session,
+	})
+}
+
+// NewTeacherRequest 添加教师 请求结构体
+type NewTeacherRequest struct {
+	Username    string `json:"username" binding:"required"`
+	TeacherName string `json:"teacher_name" binding:"required"`
+	Password    string `json:"password" binding:"required,min=6"`
+	Role        string `json:"role" binding:"omitempty,oneof=teacher admin"`
+}
+
+// NewTeacher 添加教师或管理员账号 仅管理员可用
+func NewTeacher(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req NewTeacherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+	if req.Role == "" {
+		req.Role = util.RoleTeacher
+	}
+
+	teacher, err := util.AddTeacher(req.Username, req.TeacherName, req.Password, req.Role)
+	if err != nil {
+		respondError(c, err, "添加教师失败")
+		return
+	}
+	c.JSON(http.StatusCreated, Data{
+		Code: http.StatusCreated,
+		Msg:  "添加教师成功",
+		Data: teacher,
+	})
+}
+
+// ChangePasswordRequest 修改密码 请求结构体
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangeTeacherPassword 教师修改密码
+func ChangeTeacherPassword(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	err := util.ChangeTeacherPassword(c.GetString(middleware.UserIdKey), req.OldPassword, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, Data{
+				Code: errcode.InvalidCredentials,
+				Msg:  "原密码错误",
+			})
+			return
+		}
+		respondError(c, err, "修改密码失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "修改密码成功",
+	})
+}
+
+// checkTaskOwner 检查当前用户是否有权管理任务 无权限时直接写入响应并返回false
+func checkTaskOwner(c *gin.Context, taskId string) bool {
+	err := util.CheckTaskOwner(taskId, c.GetString(middleware.UserIdKey), c.GetString(middleware.RoleKey))
+	if err == nil {
+		return true
+	}
+	respondError(c, err, "检查任务权限失败")
+	return false
+}
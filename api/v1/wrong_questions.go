@@ -0,0 +1,139 @@
+package v1
+
+import (
+	"ZhiShanYunXue/api/errcode"
+	"ZhiShanYunXue/api/middleware"
+	"ZhiShanYunXue/util"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// WrongQuestionQueryRequest 错题本筛选条件 请求结构体
+type WrongQuestionQueryRequest struct {
+	TaskId string `json:"task_id" form:"task_id"`
+	// Start、End 按提交时间筛选
+	Start string `json:"start" form:"start"`
+	End   string `json:"end" form:"end"`
+	// Tag 题目标签
+	Tag string `json:"tag" form:"tag"`
+	// KpId 知识点 包括其下级知识点
+	KpId string `json:"kp_id" form:"kp_id"`
+	// Reviewed 为空时返回全部错题
+	Reviewed *bool `json:"reviewed" form:"reviewed"`
+}
+
+// toQuery 转换为当前学生的错题本查询条件
+func (req WrongQuestionQueryRequest) toQuery(c *gin.Context) util.WrongQuestionQuery {
+	return util.WrongQuestionQuery{
+		StudentId: c.GetString(middleware.UserIdKey),
+		TaskId:    req.TaskId,
+		Start:     req.Start,
+		End:       req.End,
+		Tag:       req.Tag,
+		KpId:      req.KpId,
+		Reviewed:  req.Reviewed,
+	}
+}
+
+// ListWrongQuestions 获取当前学生的错题本
+func ListWrongQuestions(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req WrongQuestionQueryRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	questions, err := util.ListWrongQuestions(req.toQuery(c))
+	if err != nil {
+		respondError(c, err, "获取错题本失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Data: questions,
+	})
+}
+
+// MarkReviewedRequest 标记错题已复习 请求结构体
+type MarkReviewedRequest struct {
+	TaskId string `json:"task_id" binding:"required"`
+	QaId   string `json:"qa_id" binding:"required"`
+	// Reviewed 为false时取消标记 默认为true
+	Reviewed *bool `json:"reviewed"`
+}
+
+// MarkWrongQuestionReviewed 标记或取消标记错题已复习
+func MarkWrongQuestionReviewed(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req MarkReviewedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	reviewed := req.Reviewed == nil || *req.Reviewed
+	err := util.MarkWrongQuestionReviewed(c.GetString(middleware.UserIdKey), req.TaskId, req.QaId, reviewed)
+	if err != nil {
+		if errors.Is(err, util.ErrAnswerNotFound) {
+			c.JSON(http.StatusNotFound, Data{
+				Code: errcode.AnswerNotFound,
+				Msg:  "错题本中没有该题",
+			})
+			return
+		}
+		respondError(c, err, "更新复习状态失败")
+		return
+	}
+	c.JSON(http.StatusOK, Data{
+		Code: http.StatusOK,
+		Msg:  "更新复习状态成功",
+	})
+}
+
+// NewPracticeRequest 生成错题练习 请求结构体
+type NewPracticeRequest struct {
+	WrongQuestionQueryRequest
+	// Size 题目数量 默认20题
+	Size int `json:"size"`
+}
+
+// NewPractice 用错题本中的题目生成自学任务
+func NewPractice(c *gin.Context) {
+	// 日志记录
+	logger, _ := util.NewLogger()
+	// 绑定请求参数
+	var req NewPracticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, Data{
+			Code: http.StatusUnprocessableEntity,
+			Msg:  "请求格式错误或缺少必要参数",
+		})
+		return
+	}
+	logger.Info("验证数据成功")
+
+	taskId, count, err := util.CreatePracticeTask(req.toQuery(c), req.Size)
+	if err != nil {
+		respondError(c, err, "生成错题练习失败")
+		return
+	}
+	c.JSON(http.StatusCreated, Data{
+		Code: http.StatusCreated,
+		Msg:  "生成错题练习成功",
+		Data: gin.H{"task_id": taskId, "question_count": count},
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"ZhiShanYunXue/router"
+	"ZhiShanYunXue/setting"
 	"ZhiShanYunXue/util"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -37,6 +38,9 @@ func main() {
 	logger.Infof("现已改为默认监听全部地址，下面的是本机地址")
 	logger.Infof("IpAddress: http://localhost:24748/")
 
+	// 定期自动提交超时的限时作答
+	go util.RunTimeLimitSweeper(setting.TimeLimitSweepInterval)
+
 	r := router.InitRouter()
 	err := r.Run(":24748")
 	if err != nil {
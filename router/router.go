@@ -4,6 +4,7 @@ import (
 	"ZhiShanYunXue/api/middleware"
 	v1 "ZhiShanYunXue/api/v1"
 	"ZhiShanYunXue/setting"
+	"ZhiShanYunXue/util"
 	_ "embed"
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
@@ -35,18 +36,87 @@ func InitRouter() *gin.Engine {
 
 	apiBaseUrl := "/zsyx/api/" + setting.ApiVersion
 
+	// 按角色区分的登录校验
+	studentAuth := middleware.Auth(util.RoleStudent)
+	teacherAuth := middleware.Auth(util.RoleTeacher, util.RoleAdmin)
+
 	api := r.Group(apiBaseUrl)
 	{
 
 		// 任务 任务管理类
 		task := api.Group("/tasks")
 		{
-			task.POST("/new_task", v1.NewTask)
-			task.GET("/get_info", v1.GetInfo)
-			task.GET("/get_task_data", v1.GetTaskData)
-			task.GET("/get_report", v1.GetReport)
-			task.GET("/get_status", v1.GetStatusReportData)
-			task.POST("/push_answer", v1.PushAnswer)
+			task.POST("/new_task", teacherAuth, v1.NewTask)
+			task.POST("/import_task", teacherAuth, v1.ImportTask)
+			task.GET("/get_info", middleware.Auth(), v1.GetInfo)
+			task.GET("/get_task_data", studentAuth, v1.GetTaskData)
+			task.GET("/get_remaining_time", studentAuth, v1.GetRemainingTime)
+			task.GET("/get_report", studentAuth, v1.GetReport)
+			task.GET("/get_status", teacherAuth, v1.GetStatusReportData)
+			task.POST("/push_answer", studentAuth, v1.PushAnswer)
+			task.POST("/save_draft", studentAuth, v1.SaveDraft)
+			task.GET("/list", teacherAuth, v1.ListTasks)
+			task.POST("/update_task", teacherAuth, v1.UpdateTask)
+			task.POST("/close_task", teacherAuth, v1.CloseTask)
+			task.POST("/delete_task", teacherAuth, v1.DeleteTask)
+			task.POST("/release_answer", teacherAuth, v1.ReleaseAnswer)
+			task.GET("/grading_queue", teacherAuth, v1.GetGradingQueue)
+			task.POST("/grade_answer", teacherAuth, v1.GradeAnswer)
+			task.POST("/finish_grading", teacherAuth, v1.FinishGrading)
+			task.GET("/analysis", teacherAuth, v1.GetTaskAnalysis)
+			task.GET("/summary", teacherAuth, v1.GetTaskSummary)
+			task.GET("/export", teacherAuth, v1.ExportResults)
+		}
+
+		// 班级 班级与成员管理类
+		class := api.Group("/classes", teacherAuth)
+		{
+			class.POST("/new_class", v1.NewClass)
+			class.GET("/list", v1.ListClasses)
+			class.POST("/update_class", v1.UpdateClass)
+			class.POST("/delete_class", v1.DeleteClass)
+			class.GET("/get_members", v1.GetClassMembers)
+			class.POST("/add_members", v1.AddClassMembers)
+			class.POST("/remove_members", v1.RemoveClassMembers)
+		}
+
+		// 题库 可在多个任务中复用的题目
+		question := api.Group("/questions", teacherAuth)
+		{
+			question.POST("/new_question", v1.NewQuestion)
+			question.GET("/search", v1.SearchQuestions)
+			question.POST("/set_tags", v1.SetQuestionTags)
+			question.GET("/usage", v1.GetQuestionUsage)
+		}
+
+		// 知识点 知识点目录与掌握情况
+		knowledge := api.Group("/knowledge")
+		{
+			knowledge.POST("/new_point", teacherAuth, v1.NewKnowledgePoint)
+			knowledge.GET("/tree", middleware.Auth(), v1.GetKnowledgeTree)
+			knowledge.POST("/set_question_points", teacherAuth, v1.SetQuestionKnowledgePoints)
+			knowledge.GET("/mastery", middleware.Auth(), v1.GetMastery)
+		}
+
+		// 学生 账号管理类
+		student := api.Group("/students")
+		{
+			student.POST("/register", teacherAuth, v1.RegisterStudent)
+			student.POST("/activate", v1.ActivateStudent)
+			student.POST("/login", v1.LoginStudent)
+			student.POST("/logout", middleware.Auth(), v1.Logout)
+			student.GET("/wrong_questions", studentAuth, v1.ListWrongQuestions)
+			student.POST("/mark_reviewed", studentAuth, v1.MarkWrongQuestionReviewed)
+			student.POST("/new_practice", studentAuth, v1.NewPractice)
+		}
+
+		// 教师 账号管理类
+		teacher := api.Group("/teachers")
+		{
+			teacher.POST("/login", v1.LoginTeacher)
+			teacher.POST("/logout", middleware.PasswordChangeAuth(), v1.Logout)
+			teacher.POST("/change_password", middleware.PasswordChangeAuth(util.RoleTeacher, util.RoleAdmin), v1.ChangeTeacherPassword)
+			teacher.POST("/new_teacher", middleware.Auth(util.RoleAdmin), v1.NewTeacher)
 		}
 	}
 
@@ -1,11 +1,25 @@
 package setting
 
+import "time"
+
 const (
 	// DbDriverName DbDriver name SQLite DataBase
 	DbDriverName = "sqlite3"
 	DbName       = "data.sqlite"
+	// DbBusyTimeout 数据库被事务锁定时的等待时间(毫秒)
+	DbBusyTimeout = 5000
 	// ApiVersion API
 	ApiVersion = "v1"
 	// MaxTries Create UUID MaxTries
 	MaxTries = 5
+	// SessionExpire 登录会话有效期
+	SessionExpire = 7 * 24 * time.Hour
+	// DefaultAdminUsername 首次启动时创建的管理员账号
+	DefaultAdminUsername = "admin"
+	// AdminPasswordEnv 首次启动时管理员初始密码所在的环境变量 未设置时随机生成
+	AdminPasswordEnv = "ZSYX_ADMIN_PASSWORD"
+	// AdminPasswordFile 随机生成的管理员初始密码写入的文件 仅所有者可读写
+	AdminPasswordFile = "admin_password.txt"
+	// TimeLimitSweepInterval 检查并自动提交超时作答的间隔
+	TimeLimitSweepInterval = 30 * time.Second
 )
@@ -0,0 +1,277 @@
+package util
+
+import (
+	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// discriminationGroupRatio 计算区分度时高分组与低分组各占的比例
+const discriminationGroupRatio = 0.27
+
+// QuestionAnalysis 单题的项目分析结果
+type QuestionAnalysis struct {
+	QaId     string  `json:"qa_id"`
+	QaNumber int     `json:"qa_number"`
+	QaTitle  string  `json:"qa_title"`
+	QaType   string  `json:"q_type"`
+	Answer   string  `json:"answer"`
+	Points   float64 `json:"points"`
+	// Answered 作答人数 不含空白答案
+	Answered     int `json:"answered"`
+	CorrectCount int `json:"correct_count"`
+	// Difficulty 难度 即答对人数占提交人数的比例
+	Difficulty float64 `json:"difficulty"`
+	AvgScore   float64 `json:"avg_score"`
+	// Discrimination 区分度 高分组与低分组答对比例之差
+	Discrimination float64 `json:"discrimination"`
+	// OptionCounts 各选项或答案的选择人数 用于干扰项分析
+	OptionCounts map[string]int `json:"option_counts"`
+	// AvgSpendTime 平均用时(秒) 没有逐题用时记录时为空
+	AvgSpendTime *float64 `json:"avg_spend_time"`
+}
+
+// TaskAnalysis 任务的项目分析结果
+type TaskAnalysis struct {
+	TaskTitle string `json:"task_title"`
+	// Submitted 已提交学生数 为各项统计的基数
+	Submitted int `json:"submitted"`
+	// GroupSize 高分组与低分组的人数
+	GroupSize int `json:"group_size"`
+	// AvgSpendTime 完成整个任务的平均用时(秒)
+	AvgSpendTime float64            `json:"avg_spend_time"`
+	Questions    []QuestionAnalysis `json:"questions"`
+}
+
+// analysisAnswer 统计用的学生单题答案
+type analysisAnswer struct {
+	Answer    string
+	IsCorrect bool
+	Score     float64
+	SpendTime string
+}
+
+// GetTaskAnalysis 计算任务每道题的难度、区分度、选项分布与平均用时
+func GetTaskAnalysis(taskId string) (*TaskAnalysis, error) {
+	logger, _ := NewLogger()
+
+	info, err := GetInfo(taskId)
+	if err != nil {
+		return nil, err
+	}
+	analysis := &TaskAnalysis{
+		TaskTitle: info.TaskTitle,
+		Questions: []QuestionAnalysis{},
+	}
+
+	// 获取题目
+	questionRows, err := db.Query(`SELECT td.qa_id, tqr.qa_number, td.q_title, td.q_type, td.q_choice, td.points
+		FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ? ORDER BY tqr.qa_number`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(questionRows *sql.Rows) {
+		closeErr := questionRows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(questionRows)
+	for questionRows.Next() {
+		question := QuestionAnalysis{OptionCounts: map[string]int{}}
+		err = questionRows.Scan(&question.QaId, &question.QaNumber, &question.QaTitle, &question.QaType, &question.Answer, &question.Points)
+		if err != nil {
+			return nil, err
+		}
+		analysis.Questions = append(analysis.Questions, question)
+	}
+	if err = questionRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 获取已提交学生的总分 按总分从高到低排序
+	type studentTotal struct {
+		StudentId string
+		Score     float64
+	}
+	var totals []studentTotal
+	scoreRows, err := db.Query(`SELECT student_id, score FROM student_task_scores WHERE task_id = ? ORDER BY score DESC, student_id`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(scoreRows *sql.Rows) {
+		closeErr := scoreRows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(scoreRows)
+	for scoreRows.Next() {
+		var total studentTotal
+		if err = scoreRows.Scan(&total.StudentId, &total.Score); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	if err = scoreRows.Err(); err != nil {
+		return nil, err
+	}
+	analysis.Submitted = len(totals)
+
+	// 获取学生答案 按题目和学生索引
+	answers := make(map[string]map[string]analysisAnswer)
+	// 多次作答时只统计计入成绩的作答
+	answerRows, err := db.Query(`SELECT sta.student_id, sta.qa_id, sta.answer, sta.is_correct, sta.score, sta.spend_time FROM student_task_answers sta
+		INNER JOIN student_task_scores sts ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+		WHERE sta.task_id = ?`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(answerRows *sql.Rows) {
+		closeErr := answerRows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(answerRows)
+	for answerRows.Next() {
+		var studentId, qaId string
+		var answer analysisAnswer
+		if err = answerRows.Scan(&studentId, &qaId, &answer.Answer, &answer.IsCorrect, &answer.Score, &answer.SpendTime); err != nil {
+			return nil, err
+		}
+		if answers[qaId] == nil {
+			answers[qaId] = make(map[string]analysisAnswer)
+		}
+		answers[qaId][studentId] = answer
+	}
+	if err = answerRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 完成任务的平均用时
+	analysis.AvgSpendTime, err = averageTaskSpendTime(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 高分组与低分组人数 不足两人时不计算区分度
+	if analysis.Submitted >= 2 {
+		analysis.GroupSize = int(math.Max(1, math.Round(float64(analysis.Submitted)*discriminationGroupRatio)))
+	}
+
+	for i := range analysis.Questions {
+		question := &analysis.Questions[i]
+		questionAnswers := answers[question.QaId]
+
+		totalScore := 0.0
+		var spendTimes []float64
+		for _, answer := range questionAnswers {
+			// 空白答案也计入用时 学生可能思考后放弃作答
+			if seconds, err := strconv.ParseFloat(answer.SpendTime, 64); err == nil {
+				spendTimes = append(spendTimes, seconds)
+			}
+			if strings.TrimSpace(answer.Answer) == "" {
+				continue
+			}
+			question.Answered++
+			totalScore += answer.Score
+			if answer.IsCorrect {
+				question.CorrectCount++
+			}
+			for _, option := range answerOptions(question.QaType, answer.Answer) {
+				question.OptionCounts[option]++
+			}
+		}
+		if len(spendTimes) > 0 {
+			avgSpendTime := mean(spendTimes)
+			question.AvgSpendTime = &avgSpendTime
+		}
+		if analysis.Submitted > 0 {
+			question.Difficulty = float64(question.CorrectCount) / float64(analysis.Submitted)
+			question.AvgScore = totalScore / float64(analysis.Submitted)
+		}
+
+		if analysis.GroupSize > 0 {
+			upperCorrect, lowerCorrect := 0, 0
+			for j := 0; j < analysis.GroupSize; j++ {
+				if questionAnswers[totals[j].StudentId].IsCorrect {
+					upperCorrect++
+				}
+				if questionAnswers[totals[len(totals)-1-j].StudentId].IsCorrect {
+					lowerCorrect++
+				}
+			}
+			question.Discrimination = float64(upperCorrect-lowerCorrect) / float64(analysis.GroupSize)
+		}
+	}
+
+	logger.Info("任务项目分析成功")
+	return analysis, nil
+}
+
+// answerOptions 将学生答案拆分为用于分布统计的选项 主观题不统计
+func answerOptions(qType string, answer string) []string {
+	switch qType {
+	case QuestionSingle:
+		return []string{NormalizeAnswer(answer)}
+	case QuestionMultiple:
+		return strings.Split(normalizeChoices(answer), "")
+	case QuestionJudge:
+		if judged, ok := judgeAnswers[NormalizeAnswer(answer)]; ok {
+			return []string{judged}
+		}
+		return []string{NormalizeAnswer(answer)}
+	case QuestionEssay:
+		return nil
+	}
+	return []string{strings.TrimSpace(answer)}
+}
+
+// averageTaskSpendTime 已提交学生完成任务的平均用时(秒)
+func averageTaskSpendTime(taskId string) (float64, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT tt.get_task_time, tt.push_answer_time FROM task_time tt
+		INNER JOIN student_task_scores sts ON sts.student_id = tt.student_id AND sts.task_id = tt.task_id AND sts.attempt = tt.attempt
+		WHERE tt.task_id = ? AND tt.push_answer_time != ''`, taskId)
+	if err != nil {
+		return 0, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	var spendTimes []float64
+	for rows.Next() {
+		var getTaskTime, pushAnswerTime string
+		if err = rows.Scan(&getTaskTime, &pushAnswerTime); err != nil {
+			return 0, err
+		}
+		if getTaskTime == "" {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(GetSpendTimeInSeconds(getTaskTime, pushAnswerTime), 64)
+		if err != nil {
+			continue
+		}
+		spendTimes = append(spendTimes, seconds)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	return mean(spendTimes), nil
+}
+
+// mean 平均值 空切片返回0
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// 答案公布策略
+const (
+	// ReleaseNever 从不向学生公布答案
+	ReleaseNever = "never"
+	// ReleaseAfterSubmit 学生提交后公布 可多次提交的任务在用完提交次数或截止后才公布
+	ReleaseAfterSubmit = "after_submit"
+	// ReleaseAfterDeadline 任务截止后公布
+	ReleaseAfterDeadline = "after_deadline"
+	// ReleaseManual 教师手动公布
+	ReleaseManual = "manual"
+)
+
+// IsAnswerReleased 判断答案是否已对该学生公布
+func IsAnswerReleased(taskId string, studentId string) (bool, error) {
+	logger, _ := NewLogger()
+
+	var policy string
+	var released bool
+	var deadline string
+	var maxAttempts int
+	var practice bool
+	err := db.QueryRow(`SELECT answer_release, answer_released, Deadline, max_attempts, student_id != '' FROM tasks WHERE task_id = ?`, taskId).
+		Scan(&policy, &released, &deadline, &maxAttempts, &practice)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrTaskNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	deadlinePassed := func() bool {
+		t, err := ParseDeadline(deadline)
+		if err != nil {
+			logger.Warnf("无法解析任务截止时间 task_id: %s deadline: %s", taskId, deadline)
+			return false
+		}
+		return time.Now().After(t)
+	}
+
+	switch policy {
+	case ReleaseNever:
+		return false, nil
+	case ReleaseAfterSubmit:
+		var submitted int
+		err = db.QueryRow(`SELECT COUNT(DISTINCT attempt) FROM student_task_answers WHERE student_id = ? AND task_id = ?`, studentId, taskId).Scan(&submitted)
+		if err != nil {
+			return false, err
+		}
+		return answerReleasedAfterSubmit(submitted, maxAttempts, practice, deadlinePassed), nil
+	case ReleaseAfterDeadline:
+		return deadlinePassed(), nil
+	case ReleaseManual:
+		return released, nil
+	}
+	return false, nil
+}
+
+// answerReleasedAfterSubmit 提交后公布策略下是否公布答案
+// 还能再次提交时公布答案会让学生照抄答案重新提交 因此要用完提交次数或任务截止后才公布
+// 自学任务的题目答案均已公布 提交后即可查看
+func answerReleasedAfterSubmit(submitted int, maxAttempts int, practice bool, deadlinePassed func() bool) bool {
+	if submitted == 0 {
+		return false
+	}
+	if practice || (maxAttempts > 0 && submitted >= maxAttempts) {
+		return true
+	}
+	return deadlinePassed()
+}
+
+// SetAnswerReleased 手动公布或撤回答案
+func SetAnswerReleased(taskId string, released bool) error {
+	logger, _ := NewLogger()
+
+	result, err := db.Exec(`UPDATE tasks SET answer_released = ? WHERE task_id = ?`, released, taskId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTaskNotFound
+	}
+
+	logger.Info("更新答案公布状态成功")
+	return nil
+}
@@ -0,0 +1,30 @@
+package util
+
+import "testing"
+
+func TestAnswerReleasedAfterSubmit(t *testing.T) {
+	tests := []struct {
+		name           string
+		submitted      int
+		maxAttempts    int
+		practice       bool
+		deadlinePassed bool
+		want           bool
+	}{
+		{"未提交", 0, 1, false, false, false},
+		{"未提交已截止", 0, 1, false, true, false},
+		{"只能提交一次", 1, 1, false, false, true},
+		{"还能再次提交", 1, 3, false, false, false},
+		{"用完提交次数", 3, 3, false, false, true},
+		{"还能再次提交但已截止", 1, 3, false, true, true},
+		{"不限次数", 5, 0, false, false, false},
+		{"不限次数已截止", 5, 0, false, true, true},
+		{"自学任务", 1, 0, true, false, true},
+	}
+	for _, tt := range tests {
+		deadlinePassed := func() bool { return tt.deadlinePassed }
+		if got := answerReleasedAfterSubmit(tt.submitted, tt.maxAttempts, tt.practice, deadlinePassed); got != tt.want {
+			t.Errorf("%s: answerReleasedAfterSubmit() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
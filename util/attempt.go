@@ -0,0 +1,156 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// 多次作答的计分方式
+const (
+	// AttemptHighest 取最高分
+	AttemptHighest = "highest"
+	// AttemptLast 取最后一次提交
+	AttemptLast = "last"
+	// AttemptAverage 取各次提交的平均分
+	AttemptAverage = "average"
+)
+
+var (
+	// ErrNoAttemptsLeft 已达到最大提交次数
+	ErrNoAttemptsLeft = errors.New("已达到最大提交次数")
+	// ErrAttemptNotFound 作答记录不存在
+	ErrAttemptNotFound = errors.New("找不到作答记录")
+)
+
+// AttemptRecord 学生的一次作答
+type AttemptRecord struct {
+	Attempt        int     `json:"attempt"`
+	GetTaskTime    string  `json:"get_task_time"`
+	PushAnswerTime string  `json:"push_answer_time"`
+	SpendTime      string  `json:"spend_time"`
+	IsLate         bool    `json:"is_late"`
+	Score          float64 `json:"score"`
+	// Counted 是否为计入成绩的作答 按平均分计分时为最后一次提交
+	Counted bool `json:"counted"`
+}
+
+// taskAttemptSetting 获取任务的最大提交次数与计分方式
+func taskAttemptSetting(ex dbExecutor, taskId string) (maxAttempts int, policy string, err error) {
+	err = ex.QueryRow(`SELECT max_attempts, attempt_policy FROM tasks WHERE task_id = ?`, taskId).Scan(&maxAttempts, &policy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", ErrTaskNotFound
+	}
+	return maxAttempts, policy, err
+}
+
+// latestAttempt 获取学生最近一次作答的序号及是否已提交 没有作答时返回0
+func latestAttempt(ex dbExecutor, studentId string, taskId string) (attempt int, submitted bool, err error) {
+	err = ex.QueryRow(`SELECT attempt, push_answer_time != '' FROM task_time WHERE student_id = ? AND task_id = ? ORDER BY attempt DESC LIMIT 1`,
+		studentId, taskId).Scan(&attempt, &submitted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return attempt, submitted, err
+}
+
+// submittedAttemptCount 学生已提交的次数
+func submittedAttemptCount(ex dbExecutor, studentId string, taskId string) (int, error) {
+	var count int
+	err := ex.QueryRow(`SELECT COUNT(*) FROM task_time WHERE student_id = ? AND task_id = ? AND push_answer_time != ''`,
+		studentId, taskId).Scan(&count)
+	return count, err
+}
+
+// nextAttempt 获取本次提交应写入的作答序号 最近一次作答尚未提交时沿用该次 否则开始新的作答
+// 已达到最大提交次数时返回ErrNoAttemptsLeft maxAttempts为0表示不限次数
+func nextAttempt(ex dbExecutor, studentId string, taskId string, getTaskTime string) (int, error) {
+	attempt, submitted, err := latestAttempt(ex, studentId, taskId)
+	if err != nil {
+		return 0, err
+	}
+	if attempt > 0 && !submitted {
+		return attempt, nil
+	}
+
+	maxAttempts, _, err := taskAttemptSetting(ex, taskId)
+	if err != nil {
+		return 0, err
+	}
+	count, err := submittedAttemptCount(ex, studentId, taskId)
+	if err != nil {
+		return 0, err
+	}
+	if maxAttempts > 0 && count >= maxAttempts {
+		return 0, fmt.Errorf("%w: %d次", ErrNoAttemptsLeft, maxAttempts)
+	}
+
+	attempt++
+	_, err = ex.Exec(`INSERT INTO task_time (student_id, task_id, attempt, get_task_time, push_answer_time) VALUES (?, ?, ?, ?, '')`,
+		studentId, taskId, attempt, getTaskTime)
+	if err != nil {
+		return 0, err
+	}
+	return attempt, nil
+}
+
+// combineAttemptScores 按计分方式合并各次提交的得分 返回最终得分与计入成绩的作答
+// attempts按作答序号升序排列
+func combineAttemptScores(policy string, attempts []int, scores map[int]float64) (score float64, counted int) {
+	if len(attempts) == 0 {
+		return 0, 0
+	}
+	last := attempts[len(attempts)-1]
+	switch policy {
+	case AttemptLast:
+		return scores[last], last
+	case AttemptAverage:
+		total := 0.0
+		for _, attempt := range attempts {
+			total += scores[attempt]
+		}
+		return total / float64(len(attempts)), last
+	}
+	// 默认取最高分 同分时取较早的一次
+	counted = attempts[0]
+	for _, attempt := range attempts[1:] {
+		if scores[attempt] > scores[counted] {
+			counted = attempt
+		}
+	}
+	return scores[counted], counted
+}
+
+// ListAttempts 获取学生在任务中的全部作答记录 按作答序号升序
+func ListAttempts(studentId string, taskId string) ([]AttemptRecord, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT tt.attempt, tt.get_task_time, tt.push_answer_time, tt.is_late, tt.score, COALESCE(sts.attempt, 0)
+		FROM task_time tt LEFT JOIN student_task_scores sts ON sts.student_id = tt.student_id AND sts.task_id = tt.task_id
+		WHERE tt.student_id = ? AND tt.task_id = ? ORDER BY tt.attempt`, studentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	records := []AttemptRecord{}
+	for rows.Next() {
+		var record AttemptRecord
+		var countedAttempt int
+		err = rows.Scan(&record.Attempt, &record.GetTaskTime, &record.PushAnswerTime, &record.IsLate, &record.Score, &countedAttempt)
+		if err != nil {
+			return nil, err
+		}
+		if record.GetTaskTime != "" && record.PushAnswerTime != "" {
+			record.SpendTime = GetSpendTimeInSeconds(record.GetTaskTime, record.PushAnswerTime)
+		}
+		record.Counted = record.Attempt == countedAttempt
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
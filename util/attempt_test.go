@@ -0,0 +1,32 @@
+package util
+
+import "testing"
+
+func TestCombineAttemptScores(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		attempts    []int
+		scores      map[int]float64
+		wantScore   float64
+		wantCounted int
+	}{
+		{"没有作答", AttemptHighest, nil, nil, 0, 0},
+		{"最高分", AttemptHighest, []int{1, 2, 3}, map[int]float64{1: 3, 2: 8, 3: 5}, 8, 2},
+		{"最高分同分取较早", AttemptHighest, []int{1, 2, 3}, map[int]float64{1: 5, 2: 8, 3: 8}, 8, 2},
+		{"全部同分取第一次", AttemptHighest, []int{1, 2}, map[int]float64{1: 4, 2: 4}, 4, 1},
+		{"未知方式按最高分", "", []int{1, 2}, map[int]float64{1: 9, 2: 4}, 9, 1},
+		{"最后一次", AttemptLast, []int{1, 2, 3}, map[int]float64{1: 9, 2: 8, 3: 2}, 2, 3},
+		{"平均分", AttemptAverage, []int{1, 2, 3}, map[int]float64{1: 3, 2: 6, 3: 9}, 6, 3},
+		{"平均分含零分", AttemptAverage, []int{1, 2}, map[int]float64{1: 0, 2: 5}, 2.5, 2},
+		{"单次作答", AttemptAverage, []int{1}, map[int]float64{1: 7}, 7, 1},
+		// 作答序号不连续时仍按传入顺序处理
+		{"序号不连续", AttemptLast, []int{1, 3}, map[int]float64{1: 5, 3: 6}, 6, 3},
+	}
+	for _, tt := range tests {
+		score, counted := combineAttemptScores(tt.policy, tt.attempts, tt.scores)
+		if score != tt.wantScore || counted != tt.wantCounted {
+			t.Errorf("%s: combineAttemptScores() = (%v, %d), want (%v, %d)", tt.name, score, counted, tt.wantScore, tt.wantCounted)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"ZhiShanYunXue/setting"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 用户角色
+const (
+	RoleStudent = "student"
+)
+
+var (
+	// ErrUserExists 账号已存在
+	ErrUserExists = errors.New("账号已存在")
+	// ErrInvalidCredentials 账号或密码错误
+	ErrInvalidCredentials = errors.New("账号或密码错误")
+	// ErrInvalidToken 登录状态无效或已过期
+	ErrInvalidToken = errors.New("登录状态无效或已过期")
+	// ErrPasswordChangeRequired 账号需先修改初始密码
+	ErrPasswordChangeRequired = errors.New("请先修改初始密码")
+	// ErrInvalidActivationCode 激活码错误或账号已激活
+	ErrInvalidActivationCode = errors.New("激活码错误或账号已激活")
+)
+
+// Session 登录会话
+type Session struct {
+	Token      string `json:"token"`
+	UserId     string `json:"user_id"`
+	Role       string `json:"role"`
+	ExpireTime string `json:"expire_time"`
+	// MustChangePassword 需先修改初始密码 修改前只能修改密码或退出登录
+	MustChangePassword bool `json:"must_change_password"`
+}
+
+// hashPassword 生成密码哈希
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// generateToken 生成随机登录令牌
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// activationCodeLength 激活码长度
+const activationCodeLength = 12
+
+// RegisterStudent 由教师创建学生账号 返回一次性激活码 学生凭激活码自行设置密码后才能登录
+// 账号已存在但尚未激活时重新生成激活码 已激活时返回ErrUserExists
+func RegisterStudent(studentId string, studentName string) (activationCode string, err error) {
+	logger, _ := NewLogger()
+
+	var passwordHash string
+	err = db.QueryRow(`SELECT password_hash FROM students WHERE student_id = ?`, studentId).Scan(&passwordHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	exists := err == nil
+	if exists && passwordHash != "" {
+		return "", ErrUserExists
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	activationCode = token[:activationCodeLength]
+	codeHash, err := hashPassword(activationCode)
+	if err != nil {
+		return "", err
+	}
+
+	if exists {
+		_, err = db.Exec(`UPDATE students SET student_name = ?, activation_code_hash = ? WHERE student_id = ? AND password_hash = ''`,
+			studentName, codeHash, studentId)
+	} else {
+		_, err = db.Exec(`INSERT INTO students (student_id, student_name, password_hash, create_time, activation_code_hash) VALUES (?, ?, '', ?, ?)`,
+			studentId, studentName, time.Now().Format("2006-01-02 15:04:05.000"), codeHash)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	logger.Info("创建学生账号成功")
+	return activationCode, nil
+}
+
+// ActivateStudent 学生凭激活码设置密码 激活码只能使用一次
+func ActivateStudent(studentId string, activationCode string, password string) error {
+	logger, _ := NewLogger()
+
+	var codeHash string
+	err := db.QueryRow(`SELECT activation_code_hash FROM students WHERE student_id = ? AND password_hash = ''`, studentId).Scan(&codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidActivationCode
+	}
+	if err != nil {
+		return err
+	}
+	if codeHash == "" || bcrypt.CompareHashAndPassword([]byte(codeHash), []byte(activationCode)) != nil {
+		return ErrInvalidActivationCode
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	// 条件更新 避免同一激活码被并发使用两次
+	result, err := db.Exec(`UPDATE students SET password_hash = ?, activation_code_hash = '' WHERE student_id = ? AND password_hash = '' AND activation_code_hash = ?`,
+		passwordHash, studentId, codeHash)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInvalidActivationCode
+	}
+
+	logger.Info("激活学生账号成功")
+	return nil
+}
+
+// LoginStudent 学生登录 成功后返回新的会话
+func LoginStudent(studentId string, password string) (*Session, error) {
+	var passwordHash string
+	err := db.QueryRow(`SELECT password_hash FROM students WHERE student_id = ?`, studentId).Scan(&passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return createSession(studentId, RoleStudent)
+}
+
+// createSession 创建登录会话
+func createSession(userId string, role string) (*Session, error) {
+	logger, _ := NewLogger()
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// 顺带清理已过期的会话
+	now := time.Now()
+	if _, err = db.Exec(`DELETE FROM sessions WHERE expire_time <= ?`, now.Format("2006-01-02 15:04:05.000")); err != nil {
+		logger.Error("清理过期会话失败: ", err)
+	}
+
+	session := &Session{
+		Token:      token,
+		UserId:     userId,
+		Role:       role,
+		ExpireTime: now.Add(setting.SessionExpire).Format("2006-01-02 15:04:05.000"),
+	}
+	_, err = db.Exec(`INSERT INTO sessions (token, user_id, role, create_time, expire_time) VALUES (?, ?, ?, ?, ?)`,
+		session.Token, session.UserId, session.Role, now.Format("2006-01-02 15:04:05.000"), session.ExpireTime)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("创建登录会话成功")
+	return session, nil
+}
+
+// GetSession 根据令牌获取有效的登录会话
+func GetSession(token string) (*Session, error) {
+	session := &Session{Token: token}
+	err := db.QueryRow(`SELECT s.user_id, s.role, s.expire_time, COALESCE(t.must_change_password, 0) FROM sessions s
+		LEFT JOIN teachers t ON t.teacher_id = s.user_id AND s.role != ?
+		WHERE s.token = ? AND s.expire_time > ?`,
+		RoleStudent, token, time.Now().Format("2006-01-02 15:04:05.000")).Scan(&session.UserId, &session.Role, &session.ExpireTime, &session.MustChangePassword)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// DeleteSession 退出登录
+func DeleteSession(token string) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStudentActivation(t *testing.T) {
+	initTestDB(t)
+
+	code, err := RegisterStudent("2024001", "张三")
+	if err != nil {
+		t.Fatalf("RegisterStudent error: %v", err)
+	}
+	if len(code) != activationCodeLength {
+		t.Errorf("len(code) = %d, want %d", len(code), activationCodeLength)
+	}
+
+	// 激活前无法登录 空密码也不行
+	for _, password := range []string{"", code} {
+		if _, err = LoginStudent("2024001", password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("激活前登录 password %q: error = %v, want ErrInvalidCredentials", password, err)
+		}
+	}
+
+	// 未激活时重新生成激活码 旧激活码失效
+	newCode, err := RegisterStudent("2024001", "张三")
+	if err != nil {
+		t.Fatalf("重新生成激活码 error: %v", err)
+	}
+	if newCode == code {
+		t.Error("重新生成的激活码与原激活码相同")
+	}
+	tests := []struct {
+		name      string
+		studentId string
+		code      string
+	}{
+		{"旧激活码", "2024001", code},
+		{"错误激活码", "2024001", "000000000000"},
+		{"不存在的学号", "2024999", newCode},
+	}
+	for _, tt := range tests {
+		if err = ActivateStudent(tt.studentId, tt.code, "123456"); !errors.Is(err, ErrInvalidActivationCode) {
+			t.Errorf("%s: error = %v, want ErrInvalidActivationCode", tt.name, err)
+		}
+	}
+
+	if err = ActivateStudent("2024001", newCode, "123456"); err != nil {
+		t.Fatalf("ActivateStudent error: %v", err)
+	}
+	session, err := LoginStudent("2024001", "123456")
+	if err != nil {
+		t.Fatalf("激活后登录 error: %v", err)
+	}
+	if session.UserId != "2024001" || session.Role != RoleStudent {
+		t.Errorf("session = %+v", session)
+	}
+
+	// 激活码只能使用一次 已激活的账号不能重新注册
+	if err = ActivateStudent("2024001", newCode, "654321"); !errors.Is(err, ErrInvalidActivationCode) {
+		t.Errorf("重复激活 error = %v, want ErrInvalidActivationCode", err)
+	}
+	if _, err = RegisterStudent("2024001", "李四"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("注册已激活的学号 error = %v, want ErrUserExists", err)
+	}
+	if _, err = LoginStudent("2024001", "123456"); err != nil {
+		t.Errorf("重复激活后原密码登录 error: %v", err)
+	}
+}
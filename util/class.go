@@ -0,0 +1,345 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrClassNotFound 班级不存在
+	ErrClassNotFound = errors.New("找不到班级")
+	// ErrTaskNotAssigned 任务未布置给该学生
+	ErrTaskNotAssigned = errors.New("任务未布置给该学生")
+)
+
+// 学生的任务状态
+const (
+	StateNotFetched = "not_fetched"
+	StateInProgress = "in_progress"
+	StateSubmitted  = "submitted"
+)
+
+// ClassInfo 班级信息
+type ClassInfo struct {
+	ClassId     string `json:"class_id"`
+	ClassName   string `json:"class_name"`
+	OwnerId     string `json:"owner_id"`
+	CreateTime  string `json:"create_time"`
+	MemberCount int    `json:"member_count"`
+}
+
+// ClassMember 班级成员
+type ClassMember struct {
+	StudentId   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+	JoinTime    string `json:"join_time"`
+}
+
+// AddClass 新建班级
+func AddClass(className string, ownerId string) (*ClassInfo, error) {
+	logger, _ := NewLogger()
+
+	classInfo := &ClassInfo{
+		ClassId:    uuid.NewV4().String(),
+		ClassName:  className,
+		OwnerId:    ownerId,
+		CreateTime: time.Now().Format("2006-01-02 15:04:05.000"),
+	}
+	_, err := db.Exec(`INSERT INTO classes (class_id, class_name, owner_id, create_time) VALUES (?, ?, ?, ?)`,
+		classInfo.ClassId, classInfo.ClassName, classInfo.OwnerId, classInfo.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("新建班级成功")
+	return classInfo, nil
+}
+
+// ListClasses 获取班级列表 ownerId为空时返回全部班级
+func ListClasses(ownerId string) ([]ClassInfo, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT c.class_id, c.class_name, c.owner_id, c.create_time,
+			(SELECT COUNT(*) FROM class_members cm WHERE cm.class_id = c.class_id)
+		FROM classes c WHERE ? = '' OR c.owner_id = ? ORDER BY c.create_time`, ownerId, ownerId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	classes := []ClassInfo{}
+	for rows.Next() {
+		var classInfo ClassInfo
+		err = rows.Scan(&classInfo.ClassId, &classInfo.ClassName, &classInfo.OwnerId, &classInfo.CreateTime, &classInfo.MemberCount)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, classInfo)
+	}
+	return classes, rows.Err()
+}
+
+// CheckClassOwner 检查用户是否有权管理班级 管理员可管理所有班级
+func CheckClassOwner(classId string, userId string, role string) error {
+	var ownerId string
+	err := db.QueryRow(`SELECT owner_id FROM classes WHERE class_id = ?`, classId).Scan(&ownerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrClassNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if role == RoleAdmin || (role == RoleTeacher && ownerId == userId) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// UpdateClass 修改班级名称
+func UpdateClass(classId string, className string) error {
+	result, err := db.Exec(`UPDATE classes SET class_name = ? WHERE class_id = ?`, className, classId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrClassNotFound
+	}
+	return nil
+}
+
+// DeleteClass 删除班级及其成员和任务布置记录
+func DeleteClass(classId string) error {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	statements := []string{
+		`DELETE FROM class_members WHERE class_id = ?`,
+		`DELETE FROM task_classes WHERE class_id = ?`,
+		`DELETE FROM classes WHERE class_id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement, classId); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Info("删除班级成功")
+	return nil
+}
+
+// AddClassMembers 添加班级成员 已在班级中的学生自动忽略
+func AddClassMembers(classId string, studentIds []string) (added int, err error) {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(tx)
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO class_members (class_id, student_id, join_time) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer func(stmt *sql.Stmt) {
+		closeErr := stmt.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(stmt)
+
+	joinTime := time.Now().Format("2006-01-02 15:04:05.000")
+	for _, studentId := range studentIds {
+		result, err := stmt.Exec(classId, studentId, joinTime)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += int(affected)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	logger.Infof("添加班级成员成功 新增人数: %d", added)
+	return added, nil
+}
+
+// RemoveClassMembers 移除班级成员
+func RemoveClassMembers(classId string, studentIds []string) (removed int, err error) {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(tx)
+
+	for _, studentId := range studentIds {
+		result, err := tx.Exec(`DELETE FROM class_members WHERE class_id = ? AND student_id = ?`, classId, studentId)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		removed += int(affected)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	logger.Infof("移除班级成员成功 移除人数: %d", removed)
+	return removed, nil
+}
+
+// GetClassMembers 获取班级成员 未注册账号的学生姓名为空
+func GetClassMembers(classId string) ([]ClassMember, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT cm.student_id, COALESCE(s.student_name, ''), cm.join_time
+		FROM class_members cm LEFT JOIN students s ON s.student_id = cm.student_id
+		WHERE cm.class_id = ? ORDER BY cm.student_id`, classId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	members := []ClassMember{}
+	for rows.Next() {
+		var member ClassMember
+		if err = rows.Scan(&member.StudentId, &member.StudentName, &member.JoinTime); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// setTaskClasses 设置任务布置的班级 可在事务中调用
+func setTaskClasses(ex dbExecutor, taskId string, classIds []string) error {
+	if _, err := ex.Exec(`DELETE FROM task_classes WHERE task_id = ?`, taskId); err != nil {
+		return err
+	}
+	for _, classId := range classIds {
+		if _, err := ex.Exec(`INSERT OR IGNORE INTO task_classes (task_id, class_id) VALUES (?, ?)`, taskId, classId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckTaskAssigned 检查任务是否布置给了该学生 未指定班级的任务对所有学生开放
+// 学生的自学任务只对该学生开放
+func CheckTaskAssigned(taskId string, studentId string) error {
+	var ownerStudentId string
+	err := db.QueryRow(`SELECT student_id FROM tasks WHERE task_id = ?`, taskId).Scan(&ownerStudentId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if ownerStudentId != "" && ownerStudentId != studentId {
+		return ErrTaskNotAssigned
+	}
+
+	var classCount, memberCount int
+	err = db.QueryRow(`SELECT COUNT(*),
+			(SELECT COUNT(*) FROM task_classes tc INNER JOIN class_members cm ON cm.class_id = tc.class_id
+				WHERE tc.task_id = ?1 AND cm.student_id = ?2)
+		FROM task_classes WHERE task_id = ?1`, taskId, studentId).Scan(&classCount, &memberCount)
+	if err != nil {
+		return err
+	}
+	if classCount > 0 && memberCount == 0 {
+		return ErrTaskNotAssigned
+	}
+	return nil
+}
+
+// StudentStatus 学生在任务中的作答状态
+type StudentStatus struct {
+	StudentId      string `json:"student_id"`
+	StudentName    string `json:"student_name"`
+	State          string `json:"state"`
+	GetTaskTime    string `json:"get_task_time"`
+	PushAnswerTime string `json:"push_answer_time"`
+}
+
+// GetTaskStudentStatus 获取任务所有相关学生的作答状态 包括布置班级中尚未开始的学生
+// 多次作答时已提交的学生取计入成绩的作答 否则取最近一次作答
+func GetTaskStudentStatus(taskId string) ([]StudentStatus, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT ids.student_id, COALESCE(s.student_name, ''), COALESCE(tt.get_task_time, ''), COALESCE(tt.push_answer_time, '')
+		FROM (
+			SELECT cm.student_id FROM task_classes tc INNER JOIN class_members cm ON cm.class_id = tc.class_id WHERE tc.task_id = ?1
+			UNION
+			SELECT student_id FROM task_time WHERE task_id = ?1
+		) ids
+		LEFT JOIN students s ON s.student_id = ids.student_id
+		LEFT JOIN student_task_scores sts ON sts.student_id = ids.student_id AND sts.task_id = ?1
+		LEFT JOIN task_time tt ON tt.student_id = ids.student_id AND tt.task_id = ?1 AND tt.attempt = COALESCE(sts.attempt,
+			(SELECT MAX(attempt) FROM task_time WHERE student_id = ids.student_id AND task_id = ?1))
+		ORDER BY ids.student_id`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	statuses := []StudentStatus{}
+	for rows.Next() {
+		var status StudentStatus
+		if err = rows.Scan(&status.StudentId, &status.StudentName, &status.GetTaskTime, &status.PushAnswerTime); err != nil {
+			return nil, err
+		}
+		switch {
+		case status.PushAnswerTime != "":
+			status.State = StateSubmitted
+		case status.GetTaskTime != "":
+			status.State = StateInProgress
+		default:
+			status.State = StateNotFetched
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
@@ -0,0 +1,110 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// DeadlineLayout 截止时间的存储格式
+const DeadlineLayout = "2006-01-02 15:04:05"
+
+var (
+	// ErrInvalidDeadline 截止时间格式错误
+	ErrInvalidDeadline = errors.New("截止时间格式错误")
+	// ErrDeadlinePassed 任务已截止且不允许迟交
+	ErrDeadlinePassed = errors.New("任务已截止")
+)
+
+// deadlineLayouts 可接受的截止时间格式 仅有日期时视为当天结束
+var deadlineLayouts = []string{
+	DeadlineLayout,
+	"2006-01-02 15:04:05.000",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+}
+
+// ParseDeadline 解析截止时间
+func ParseDeadline(deadline string) (time.Time, error) {
+	for _, layout := range deadlineLayouts {
+		t, err := time.ParseInLocation(layout, deadline, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+		t, err := time.ParseInLocation(layout, deadline, time.Local)
+		if err == nil {
+			return t.Add(24*time.Hour - time.Second), nil
+		}
+	}
+	return time.Time{}, ErrInvalidDeadline
+}
+
+// NormalizeDeadline 校验截止时间并转换为统一的存储格式
+func NormalizeDeadline(deadline string) (string, error) {
+	t, err := ParseDeadline(deadline)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(DeadlineLayout), nil
+}
+
+// CheckSubmitDeadline 检查当前是否还能提交答案 返回是否为迟交
+func CheckSubmitDeadline(taskId string) (late bool, err error) {
+	logger, _ := NewLogger()
+
+	var deadline string
+	var allowLate bool
+	err = db.QueryRow(`SELECT Deadline, allow_late FROM tasks WHERE task_id = ?`, taskId).Scan(&deadline, &allowLate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrTaskNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	late, err = checkDeadlineAt(deadline, allowLate, time.Now())
+	if errors.Is(err, ErrInvalidDeadline) {
+		// 旧版本任务的截止时间为任意文本 无法解析时不做限制
+		logger.Warnf("无法解析任务截止时间 task_id: %s deadline: %s", taskId, deadline)
+		return false, nil
+	}
+	return late, err
+}
+
+// checkDeadlineAt 判断在指定时刻提交是否迟交 不允许迟交时返回ErrDeadlinePassed
+func checkDeadlineAt(deadline string, allowLate bool, now time.Time) (late bool, err error) {
+	t, err := ParseDeadline(deadline)
+	if err != nil {
+		return false, err
+	}
+	if !now.After(t) {
+		return false, nil
+	}
+	if !allowLate {
+		return true, ErrDeadlinePassed
+	}
+	return true, nil
+}
+
+// lateAt 判断在指定时刻提交是否为迟交 截止时间无法解析时视为未迟交
+func lateAt(ex dbExecutor, taskId string, at time.Time) (bool, error) {
+	var deadline string
+	err := ex.QueryRow(`SELECT Deadline FROM tasks WHERE task_id = ?`, taskId).Scan(&deadline)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrTaskNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	t, err := ParseDeadline(deadline)
+	if err != nil {
+		return false, nil
+	}
+	return at.After(t), nil
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDeadline(t *testing.T) {
+	want := time.Date(2030, 1, 2, 15, 4, 0, 0, time.Local)
+	tests := []struct {
+		deadline string
+		want     time.Time
+	}{
+		{"2030-01-02 15:04:00", want},
+		{"2030-01-02 15:04:00.000", want},
+		{"2030-01-02 15:04", want},
+		{"2030-01-02T15:04:00", want},
+		{"2030-01-02T15:04", want},
+		{"2030/01/02 15:04:00", want},
+		{"2030/01/02 15:04", want},
+		{"2030-01-02T07:04:00Z", time.Date(2030, 1, 2, 7, 4, 0, 0, time.UTC)},
+		// 仅有日期时视为当天结束
+		{"2030-01-02", time.Date(2030, 1, 2, 23, 59, 59, 0, time.Local)},
+		{"2030/01/02", time.Date(2030, 1, 2, 23, 59, 59, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDeadline(tt.deadline)
+		if err != nil {
+			t.Errorf("ParseDeadline(%q) error: %v", tt.deadline, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDeadline(%q) = %v, want %v", tt.deadline, got, tt.want)
+		}
+	}
+}
+
+func TestParseDeadlineInvalid(t *testing.T) {
+	for _, deadline := range []string{"", "下周五", "2030-13-01", "01/02/2030", "2030-01-02 25:00"} {
+		if _, err := ParseDeadline(deadline); !errors.Is(err, ErrInvalidDeadline) {
+			t.Errorf("ParseDeadline(%q) error = %v, want ErrInvalidDeadline", deadline, err)
+		}
+	}
+}
+
+func TestNormalizeDeadline(t *testing.T) {
+	tests := []struct {
+		deadline string
+		want     string
+	}{
+		{"2030-01-02 15:04", "2030-01-02 15:04:00"},
+		{"2030/01/02", "2030-01-02 23:59:59"},
+		{"2030-01-02 15:04:05.678", "2030-01-02 15:04:05"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeDeadline(tt.deadline)
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeDeadline(%q) = %q, %v, want %q", tt.deadline, got, err, tt.want)
+		}
+	}
+	if _, err := NormalizeDeadline("明天"); !errors.Is(err, ErrInvalidDeadline) {
+		t.Errorf("NormalizeDeadline(明天) error = %v, want ErrInvalidDeadline", err)
+	}
+}
+
+func TestCheckDeadlineAt(t *testing.T) {
+	deadline := "2030-01-02 12:00:00"
+	before := time.Date(2030, 1, 2, 11, 59, 59, 0, time.Local)
+	exact := time.Date(2030, 1, 2, 12, 0, 0, 0, time.Local)
+	after := time.Date(2030, 1, 2, 12, 0, 1, 0, time.Local)
+	tests := []struct {
+		name      string
+		deadline  string
+		allowLate bool
+		now       time.Time
+		wantLate  bool
+		wantErr   error
+	}{
+		{"截止前", deadline, false, before, false, nil},
+		{"恰好截止", deadline, false, exact, false, nil},
+		{"截止后不允许迟交", deadline, false, after, true, ErrDeadlinePassed},
+		{"截止后允许迟交", deadline, true, after, true, nil},
+		{"截止前允许迟交", deadline, true, before, false, nil},
+		{"旧版本任意文本", "尽快完成", false, after, false, ErrInvalidDeadline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			late, err := checkDeadlineAt(tt.deadline, tt.allowLate, tt.now)
+			if late != tt.wantLate || !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkDeadlineAt() = (%v, %v), want (%v, %v)", late, err, tt.wantLate, tt.wantErr)
+			}
+		})
+	}
+}
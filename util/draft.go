@@ -0,0 +1,111 @@
+package util
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SaveDraft 保存学生尚未提交的答案 同一题目重复保存时覆盖之前的草稿
+// 最近一次作答已提交时开始新的作答 已达到最大提交次数时返回ErrNoAttemptsLeft
+func SaveDraft(studentId string, taskId string, taskData []StuTaskData) (saveTime string, err error) {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer rollback(tx)
+
+	if err = checkTimeLimit(tx, studentId, taskId); err != nil {
+		return "", err
+	}
+	saveTime = time.Now().Format("2006-01-02 15:04:05.000")
+	if _, err = nextAttempt(tx, studentId, taskId, saveTime); err != nil {
+		return "", err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO answer_drafts (student_id, task_id, qa_id, answer, spend_time, save_time) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (student_id, task_id, qa_id) DO UPDATE SET answer = excluded.answer, spend_time = excluded.spend_time, save_time = excluded.save_time`)
+	if err != nil {
+		return "", err
+	}
+	defer func(stmt *sql.Stmt) {
+		closeErr := stmt.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(stmt)
+	for _, data := range taskData {
+		var count int
+		err = tx.QueryRow(`SELECT COUNT(*) FROM task_qa_relations WHERE task_id = ? AND qa_id = ?`, taskId, data.QaId).Scan(&count)
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "", fmt.Errorf("%w: %s", ErrQuestionNotFound, data.QaId)
+		}
+		if _, err = stmt.Exec(studentId, taskId, data.QaId, data.QAnswer, data.SpendTime, saveTime); err != nil {
+			return "", err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	logger.Info("保存草稿成功")
+	return saveTime, nil
+}
+
+// GetDraft 获取学生保存的草稿 按题目ID索引
+func GetDraft(studentId string, taskId string) (map[string]StuTaskData, error) {
+	return getDraft(db, studentId, taskId)
+}
+
+// getDraft 获取草稿的具体实现 可在事务中调用
+func getDraft(ex dbExecutor, studentId string, taskId string) (map[string]StuTaskData, error) {
+	logger, _ := NewLogger()
+
+	rows, err := ex.Query(`SELECT qa_id, answer, spend_time FROM answer_drafts WHERE student_id = ? AND task_id = ?`, studentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	drafts := make(map[string]StuTaskData)
+	for rows.Next() {
+		var data StuTaskData
+		if err = rows.Scan(&data.QaId, &data.QAnswer, &data.SpendTime); err != nil {
+			return nil, err
+		}
+		drafts[data.QaId] = data
+	}
+	return drafts, rows.Err()
+}
+
+// mergeDraft 将草稿合并到提交的答案中 提交中已有的题目以提交为准 并删除草稿 在提交的事务中调用
+func mergeDraft(ex dbExecutor, studentId string, taskId string, taskData []StuTaskData) ([]StuTaskData, error) {
+	drafts, err := getDraft(ex, studentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	merged := make([]StuTaskData, 0, len(taskData)+len(drafts))
+	for _, data := range taskData {
+		delete(drafts, data.QaId)
+		merged = append(merged, data)
+	}
+	for _, draft := range drafts {
+		merged = append(merged, draft)
+	}
+
+	if _, err = ex.Exec(`DELETE FROM answer_drafts WHERE student_id = ? AND task_id = ?`, studentId, taskId); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
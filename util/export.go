@@ -0,0 +1,231 @@
+package util
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 导出格式
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+)
+
+// utf8BOM 写在CSV开头 使Excel按UTF-8识别中文
+const utf8BOM = "\ufeff"
+
+// ResultTable 任务成绩表 每个学生一行 每道题一列
+type ResultTable struct {
+	TaskTitle string
+	Header    []string
+	Rows      [][]string
+}
+
+// GetResultTable 生成任务成绩表 classId不为空时只包含该班级的学生
+func GetResultTable(taskId string, classId string) (*ResultTable, error) {
+	logger, _ := NewLogger()
+
+	info, err := GetInfo(taskId)
+	if err != nil {
+		return nil, err
+	}
+	table := &ResultTable{TaskTitle: info.TaskTitle}
+
+	// 题目按题号排列为列
+	questionRows, err := db.Query(`SELECT tqr.qa_id, tqr.qa_number FROM task_qa_relations tqr
+		WHERE tqr.task_id = ? ORDER BY tqr.qa_number`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(questionRows *sql.Rows) {
+		closeErr := questionRows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(questionRows)
+	var qaIds []string
+	table.Header = []string{"学号", "姓名"}
+	for questionRows.Next() {
+		var qaId string
+		var qaNumber int
+		if err = questionRows.Scan(&qaId, &qaNumber); err != nil {
+			return nil, err
+		}
+		qaIds = append(qaIds, qaId)
+		table.Header = append(table.Header, strconv.Itoa(qaNumber))
+	}
+	if err = questionRows.Err(); err != nil {
+		return nil, err
+	}
+	table.Header = append(table.Header, "得分", "满分", "用时(秒)", "完成时间", "是否迟交")
+
+	// 学生答案 按学生和题目索引 多次作答时取计入成绩的作答
+	answers := make(map[string]map[string]string)
+	answerRows, err := db.Query(`SELECT sta.student_id, sta.qa_id, sta.answer FROM student_task_answers sta
+		INNER JOIN student_task_scores sts ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+		WHERE sta.task_id = ?`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(answerRows *sql.Rows) {
+		closeErr := answerRows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(answerRows)
+	for answerRows.Next() {
+		var studentId, qaId, answer string
+		if err = answerRows.Scan(&studentId, &qaId, &answer); err != nil {
+			return nil, err
+		}
+		if answers[studentId] == nil {
+			answers[studentId] = make(map[string]string)
+		}
+		answers[studentId][qaId] = answer
+	}
+	if err = answerRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 统计范围内的学生
+	statuses, err := GetTaskStudentStatus(taskId)
+	if err != nil {
+		return nil, err
+	}
+	var inClass map[string]bool
+	if classId != "" {
+		members, err := GetClassMembers(classId)
+		if err != nil {
+			return nil, err
+		}
+		inClass = make(map[string]bool)
+		for _, member := range members {
+			inClass[member.StudentId] = true
+		}
+	}
+
+	for _, status := range statuses {
+		if inClass != nil && !inClass[status.StudentId] {
+			continue
+		}
+		row := []string{status.StudentId, status.StudentName}
+		for _, qaId := range qaIds {
+			row = append(row, answers[status.StudentId][qaId])
+		}
+
+		score, fullScore, spendTime, isLate := "", "", "", ""
+		if status.State == StateSubmitted {
+			taskScore, err := GetTaskScore(status.StudentId, taskId)
+			if err != nil {
+				return nil, err
+			}
+			if taskScore != nil {
+				score = strconv.FormatFloat(taskScore.Score, 'f', -1, 64)
+				fullScore = strconv.FormatFloat(taskScore.FullScore, 'f', -1, 64)
+			}
+			// 没有获取任务时间的旧数据无法计算用时 留空
+			if status.GetTaskTime != "" && status.PushAnswerTime != "" {
+				spendTime = GetSpendTimeInSeconds(status.GetTaskTime, status.PushAnswerTime)
+			}
+			var late bool
+			err = db.QueryRow(`SELECT tt.is_late FROM task_time tt
+				INNER JOIN student_task_scores sts ON sts.student_id = tt.student_id AND sts.task_id = tt.task_id AND sts.attempt = tt.attempt
+				WHERE tt.student_id = ? AND tt.task_id = ?`, status.StudentId, taskId).Scan(&late)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			isLate = "否"
+			if late {
+				isLate = "是"
+			}
+		}
+		row = append(row, score, fullScore, spendTime, status.PushAnswerTime, isLate)
+		table.Rows = append(table.Rows, row)
+	}
+
+	logger.Info("生成成绩表成功")
+	return table, nil
+}
+
+// WriteCSV 将成绩表写为带BOM的UTF-8 CSV 可能被当作公式的单元格会被转义
+func (table *ResultTable) WriteCSV(w io.Writer) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(table.escapedRecords()); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// WriteXLSX 将成绩表写为Excel工作簿 所有单元格按字符串写入 不会被当作公式
+func (table *ResultTable) WriteXLSX(w io.Writer) error {
+	logger, _ := NewLogger()
+
+	file := excelize.NewFile()
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}()
+
+	sheet := file.GetSheetName(0)
+	streamWriter, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+	// 字符串按原样写为文本单元格 不加'前缀 否则Excel会显示出'
+	for i, record := range append([][]string{table.Header}, table.Rows...) {
+		values := make([]interface{}, len(record))
+		for j, value := range record {
+			values[j] = value
+		}
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+		if err = streamWriter.SetRow(cell, values); err != nil {
+			return err
+		}
+	}
+	if err = streamWriter.Flush(); err != nil {
+		return err
+	}
+	_, err = file.WriteTo(w)
+	return err
+}
+
+// formulaPrefixes 表格软件会将以这些字符开头的单元格当作公式
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeCell 以'前缀转义可能被当作公式的单元格 数值保持原样 仅用于CSV
+// XLSX单元格按字符串写入 不会被当作公式
+func escapeCell(value string) string {
+	if value == "" || !strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+// escapedRecords 返回表头与各行转义后的副本
+func (table *ResultTable) escapedRecords() [][]string {
+	records := make([][]string, 0, len(table.Rows)+1)
+	for _, record := range append([][]string{table.Header}, table.Rows...) {
+		escaped := make([]string, len(record))
+		for i, value := range record {
+			escaped[i] = escapeCell(value)
+		}
+		records = append(records, escaped)
+	}
+	return records
+}
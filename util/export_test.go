@@ -0,0 +1,141 @@
+package util
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestEscapeCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"张三", "张三"},
+		{"2024001", "2024001"},
+		{"=1+1", "'=1+1"},
+		{"+1+cmd", "'+1+cmd"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+		// 数值保持原样 以免成绩列变为文本
+		{"-1.5", "-1.5"},
+		{"+3", "+3"},
+	}
+	for _, tt := range tests {
+		if got := escapeCell(tt.value); got != tt.want {
+			t.Errorf("escapeCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// injectionTable 学生姓名中带有公式的成绩表
+func injectionTable() *ResultTable {
+	return &ResultTable{
+		TaskTitle: "t",
+		Header:    []string{"学号", "姓名", "总分"},
+		Rows: [][]string{
+			{"2024001", "=HYPERLINK(\"http://x\",\"y\")", "8"},
+			{"2024002", "@cmd", "-1"},
+		},
+	}
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	if err := injectionTable().WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV error: %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), utf8BOM))).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv error: %v", err)
+	}
+	if got := records[1][1]; got != "'=HYPERLINK(\"http://x\",\"y\")" {
+		t.Errorf("records[1][1] = %q", got)
+	}
+	if got := records[2][1]; got != "'@cmd" {
+		t.Errorf("records[2][1] = %q", got)
+	}
+	if got := records[2][2]; got != "-1" {
+		t.Errorf("records[2][2] = %q, want -1", got)
+	}
+}
+
+func TestWriteXLSXWritesText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := injectionTable().WriteXLSX(&buf); err != nil {
+		t.Fatalf("WriteXLSX error: %v", err)
+	}
+	file, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatalf("open xlsx error: %v", err)
+	}
+	defer file.Close()
+
+	sheet := file.GetSheetName(0)
+	for _, cell := range []string{"B2", "B3"} {
+		formula, err := file.GetCellFormula(sheet, cell)
+		if err != nil {
+			t.Fatalf("GetCellFormula(%s) error: %v", cell, err)
+		}
+		if formula != "" {
+			t.Errorf("%s formula = %q, want none", cell, formula)
+		}
+	}
+	// XLSX单元格写入原始字符串 不加'前缀
+	rows, err := file.GetRows(sheet)
+	if err != nil {
+		t.Fatalf("GetRows error: %v", err)
+	}
+	want := injectionTable()
+	for i, row := range want.Rows {
+		for j, value := range row {
+			if rows[i+1][j] != value {
+				t.Errorf("row %d col %d = %q, want %q", i+1, j, rows[i+1][j], value)
+			}
+		}
+	}
+	cellType, err := file.GetCellType(sheet, "B2")
+	if err != nil {
+		t.Fatalf("GetCellType error: %v", err)
+	}
+	if cellType != excelize.CellTypeInlineString && cellType != excelize.CellTypeSharedString {
+		t.Errorf("B2 cell type = %v, want string", cellType)
+	}
+}
+
+func TestGetResultTableWithoutGetTaskTime(t *testing.T) {
+	initTestDB(t)
+	qaIds := addTestTask(t, "t1")
+
+	// 未获取任务直接提交时没有开始时间 用时留空
+	taskData := []StuTaskData{{QaId: qaIds[0], QAnswer: "A"}, {QaId: qaIds[1], QAnswer: "B"}}
+	if _, err := PushTaskData("s1", "t1", &taskData, "2024-01-02 08:10:00.000", false); err != nil {
+		t.Fatal(err)
+	}
+	table, err := GetResultTable("t1", "")
+	if err != nil {
+		t.Fatalf("GetResultTable error: %v", err)
+	}
+	if len(table.Rows) != 1 {
+		t.Fatalf("rows = %+v, want 1", table.Rows)
+	}
+	spendTimeCol := -1
+	for i, name := range table.Header {
+		if strings.Contains(name, "用时") {
+			spendTimeCol = i
+		}
+	}
+	if spendTimeCol < 0 {
+		t.Fatalf("表头中没有用时列: %v", table.Header)
+	}
+	if got := table.Rows[0][spendTimeCol]; got != "" {
+		t.Errorf("用时 = %q, want empty", got)
+	}
+}
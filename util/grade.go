@@ -0,0 +1,239 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TaskScore 学生任务得分 结构体
+type TaskScore struct {
+	Score      float64 `json:"score"`
+	FullScore  float64 `json:"full_score"`
+	GradedTime string  `json:"graded_time"`
+	// GradingComplete 是否已完成评分 有主观题时需教师评分完成后才为true
+	GradingComplete bool `json:"grading_complete"`
+	// Attempt 计入成绩的作答
+	Attempt int `json:"attempt"`
+}
+
+// NormalizeAnswer 统一答案格式 去除空白并转为大写
+func NormalizeAnswer(answer string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(answer), ""))
+}
+
+// JudgeAnswer 判断学生答案是否正确
+func JudgeAnswer(teaAnswer string, stuAnswer string) bool {
+	stu := NormalizeAnswer(stuAnswer)
+	if stu == "" {
+		return false
+	}
+	return stu == NormalizeAnswer(teaAnswer)
+}
+
+// GradeStudentTask 根据已提交的答案为学生任务评分 并写入每题得分与总分
+func GradeStudentTask(studentId string, taskId string) (*TaskScore, error) {
+	return gradeStudentTask(db, studentId, taskId)
+}
+
+// questionKey 评分所需的题目答案与设置
+type questionKey struct {
+	Key    string
+	Type   string
+	Config QuestionConfig
+	Points float64
+}
+
+// gradeStudentTask 评分的具体实现 可在事务中调用
+func gradeStudentTask(ex dbExecutor, studentId string, taskId string) (*TaskScore, error) {
+	logger, _ := NewLogger()
+
+	// 获取任务的正确答案
+	keyRows, err := ex.Query(`SELECT td.qa_id, td.q_choice, td.q_type, td.q_config, td.points FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ?`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(keyRows *sql.Rows) {
+		closeErr := keyRows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(keyRows)
+
+	keyMap := make(map[string]questionKey)
+	for keyRows.Next() {
+		var qaId string
+		var key questionKey
+		var qConfig string
+		if err = keyRows.Scan(&qaId, &key.Key, &key.Type, &qConfig, &key.Points); err != nil {
+			return nil, err
+		}
+		key.Config = ParseQuestionConfig(key.Type, qConfig)
+		keyMap[qaId] = key
+	}
+	if err = keyRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 获取学生各次提交的答案 人工评过的题目保留教师给出的分数
+	stuRows, err := ex.Query(`SELECT attempt, qa_id, answer, graded_by, score FROM student_task_answers WHERE student_id = ? AND task_id = ?`, studentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(stuRows *sql.Rows) {
+		closeErr := stuRows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(stuRows)
+
+	type stuAnswer struct {
+		Attempt  int
+		QaId     string
+		Answer   string
+		GradedBy string
+		Score    float64
+	}
+	var stuAnswers []stuAnswer
+	for stuRows.Next() {
+		var answer stuAnswer
+		if err = stuRows.Scan(&answer.Attempt, &answer.QaId, &answer.Answer, &answer.GradedBy, &answer.Score); err != nil {
+			return nil, err
+		}
+		stuAnswers = append(stuAnswers, answer)
+	}
+	if err = stuRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 逐题按题型和分值评分 主观题等待教师人工评分
+	updateStmt, err := ex.Prepare(`UPDATE student_task_answers SET is_correct = ?, score = ?, needs_grading = ? WHERE student_id = ? AND task_id = ? AND qa_id = ? AND attempt = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		closeErr := updateStmt.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}()
+
+	taskScore := &TaskScore{
+		GradedTime: time.Now().Format("2006-01-02 15:04:05.000"),
+	}
+	for _, key := range keyMap {
+		taskScore.FullScore += key.Points
+	}
+	needsGrading := false
+	attemptScores := make(map[int]float64)
+	for _, answer := range stuAnswers {
+		score := 0.0
+		pending := false
+		if key, ok := keyMap[answer.QaId]; ok {
+			if answer.GradedBy != "" {
+				score = answer.Score
+			} else {
+				ratio, autoGraded := GradeQuestion(key.Type, key.Key, key.Config, answer.Answer)
+				score = ratio * key.Points
+				pending = !autoGraded
+			}
+		}
+		needsGrading = needsGrading || pending
+		// 得满分的题目记为正确
+		correct := score > 0 && score == keyMap[answer.QaId].Points
+		attemptScores[answer.Attempt] += score
+
+		if _, err = updateStmt.Exec(correct, score, pending, studentId, taskId, answer.QaId, answer.Attempt); err != nil {
+			return nil, err
+		}
+	}
+
+	// 写入每次提交的得分 并按任务的计分方式合并为最终得分
+	// 旧版本数据中未获取任务直接提交的答案没有答题时间记录 也计入提交
+	attempts, err := submittedAttempts(ex, studentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := range attemptScores {
+		found := false
+		for _, submitted := range attempts {
+			found = found || submitted == attempt
+		}
+		if !found {
+			attempts = append(attempts, attempt)
+		}
+	}
+	sort.Ints(attempts)
+	for _, attempt := range attempts {
+		_, err = ex.Exec(`UPDATE task_time SET score = ? WHERE student_id = ? AND task_id = ? AND attempt = ?`,
+			attemptScores[attempt], studentId, taskId, attempt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, policy, err := taskAttemptSetting(ex, taskId)
+	if err != nil {
+		return nil, err
+	}
+	taskScore.Score, taskScore.Attempt = combineAttemptScores(policy, attempts, attemptScores)
+
+	// 写入总分 有待评主观题时标记为未完成评分 否则保持原有评分状态
+	_, err = ex.Exec(`INSERT INTO student_task_scores (student_id, task_id, score, full_score, graded_time, grading_complete, attempt) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (student_id, task_id) DO UPDATE SET score = excluded.score, full_score = excluded.full_score, graded_time = excluded.graded_time,
+			attempt = excluded.attempt, grading_complete = grading_complete AND excluded.grading_complete`,
+		studentId, taskId, taskScore.Score, taskScore.FullScore, taskScore.GradedTime, !needsGrading, taskScore.Attempt)
+	if err != nil {
+		return nil, err
+	}
+	err = ex.QueryRow(`SELECT grading_complete FROM student_task_scores WHERE student_id = ? AND task_id = ?`, studentId, taskId).
+		Scan(&taskScore.GradingComplete)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("任务评分成功")
+	return taskScore, nil
+}
+
+// submittedAttempts 学生已提交的作答序号 按升序排列
+func submittedAttempts(ex dbExecutor, studentId string, taskId string) ([]int, error) {
+	logger, _ := NewLogger()
+
+	rows, err := ex.Query(`SELECT attempt FROM task_time WHERE student_id = ? AND task_id = ? AND push_answer_time != '' ORDER BY attempt`,
+		studentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	var attempts []int
+	for rows.Next() {
+		var attempt int
+		if err = rows.Scan(&attempt); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// GetTaskScore 获取学生任务得分 未评分时返回nil
+func GetTaskScore(studentId string, taskId string) (*TaskScore, error) {
+	taskScore := &TaskScore{}
+	err := db.QueryRow(`SELECT score, full_score, graded_time, grading_complete, attempt FROM student_task_scores WHERE student_id = ? AND task_id = ?`, studentId, taskId).
+		Scan(&taskScore.Score, &taskScore.FullScore, &taskScore.GradedTime, &taskScore.GradingComplete, &taskScore.Attempt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return taskScore, nil
+}
@@ -0,0 +1,44 @@
+package util
+
+import "testing"
+
+func TestNormalizeAnswer(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"a", "A"},
+		{" b ", "B"},
+		{"a c\td\n", "ACD"},
+		{"", ""},
+		{"   ", ""},
+		{"对", "对"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeAnswer(tt.answer); got != tt.want {
+			t.Errorf("NormalizeAnswer(%q) = %q, want %q", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestJudgeAnswer(t *testing.T) {
+	tests := []struct {
+		key    string
+		answer string
+		want   bool
+	}{
+		{"A", "A", true},
+		{"A", "a", true},
+		{"A", " a ", true},
+		{"A", "B", false},
+		{"A", "", false},
+		{"", "", false},
+		{"AB", "A B", true},
+		{"AB", "BA", false},
+	}
+	for _, tt := range tests {
+		if got := JudgeAnswer(tt.key, tt.answer); got != tt.want {
+			t.Errorf("JudgeAnswer(%q, %q) = %v, want %v", tt.key, tt.answer, got, tt.want)
+		}
+	}
+}
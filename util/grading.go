@@ -0,0 +1,144 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrAnswerNotFound 学生未作答该题
+	ErrAnswerNotFound = errors.New("找不到学生答案")
+	// ErrSubmissionNotFound 学生未提交该任务
+	ErrSubmissionNotFound = errors.New("找不到提交记录")
+	// ErrInvalidScore 评分超出本题分值
+	ErrInvalidScore = errors.New("评分超出本题分值范围")
+	// ErrGradingIncomplete 仍有题目未评分
+	ErrGradingIncomplete = errors.New("仍有题目未评分")
+)
+
+// GradingItem 待评分的学生答案
+type GradingItem struct {
+	StudentId    string  `json:"student_id"`
+	StudentName  string  `json:"student_name"`
+	QaId         string  `json:"qa_id"`
+	QaNumber     int     `json:"qa_number"`
+	QaTitle      string  `json:"qa_title"`
+	QaType       string  `json:"q_type"`
+	Attempt      int     `json:"attempt"`
+	Answer       string  `json:"answer"`
+	Score        float64 `json:"score"`
+	Points       float64 `json:"points"`
+	NeedsGrading bool    `json:"needs_grading"`
+	GradedBy     string  `json:"graded_by"`
+	Comment      string  `json:"comment"`
+}
+
+// ListGradingQueue 获取任务的评分队列 qaId不为空时只返回该题 includeGraded为true时包括已人工评分的答案
+func ListGradingQueue(taskId string, qaId string, includeGraded bool) ([]GradingItem, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT sta.student_id, COALESCE(s.student_name, ''), sta.qa_id, tqr.qa_number, td.q_title, td.q_type,
+			sta.attempt, sta.answer, sta.score, td.points, sta.needs_grading, sta.graded_by, sta.comment
+		FROM student_task_answers sta
+		INNER JOIN task_data td ON td.qa_id = sta.qa_id
+		INNER JOIN task_qa_relations tqr ON tqr.task_id = sta.task_id AND tqr.qa_id = sta.qa_id
+		LEFT JOIN students s ON s.student_id = sta.student_id
+		WHERE sta.task_id = ?1 AND (?2 = '' OR sta.qa_id = ?2)
+			AND (sta.needs_grading = 1 OR (?3 AND sta.graded_by != ''))
+		ORDER BY tqr.qa_number, sta.student_id, sta.attempt`, taskId, qaId, includeGraded)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	items := []GradingItem{}
+	for rows.Next() {
+		var item GradingItem
+		err = rows.Scan(&item.StudentId, &item.StudentName, &item.QaId, &item.QaNumber, &item.QaTitle, &item.QaType,
+			&item.Attempt, &item.Answer, &item.Score, &item.Points, &item.NeedsGrading, &item.GradedBy, &item.Comment)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GradeAnswer 教师为学生的单题答案评分并填写评语 评分后重新计算任务总分
+// attempt为0时评分该学生最近一次作答中的答案
+func GradeAnswer(taskId string, studentId string, qaId string, attempt int, score float64, comment string, graderId string) (*TaskScore, error) {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	var points float64
+	err = tx.QueryRow(`SELECT sta.attempt, td.points FROM student_task_answers sta INNER JOIN task_data td ON td.qa_id = sta.qa_id
+		WHERE sta.task_id = ?1 AND sta.student_id = ?2 AND sta.qa_id = ?3 AND (?4 = 0 OR sta.attempt = ?4)
+		ORDER BY sta.attempt DESC LIMIT 1`, taskId, studentId, qaId, attempt).Scan(&attempt, &points)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAnswerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if score < 0 || score > points {
+		return nil, fmt.Errorf("%w: 0 - %g", ErrInvalidScore, points)
+	}
+
+	_, err = tx.Exec(`UPDATE student_task_answers SET score = ?, graded_by = ?, comment = ?, needs_grading = 0
+		WHERE task_id = ? AND student_id = ? AND qa_id = ? AND attempt = ?`, score, graderId, comment, taskId, studentId, qaId, attempt)
+	if err != nil {
+		return nil, err
+	}
+	taskScore, err := gradeStudentTask(tx, studentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("人工评分成功")
+	return taskScore, nil
+}
+
+// FinishGrading 标记学生的提交已完成评分 之后学生可在报告中看到评语与最终得分
+func FinishGrading(taskId string, studentId string) error {
+	logger, _ := NewLogger()
+
+	var pending int
+	err := db.QueryRow(`SELECT COUNT(*) FROM student_task_answers WHERE task_id = ? AND student_id = ? AND needs_grading = 1`,
+		taskId, studentId).Scan(&pending)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: 剩余%d题", ErrGradingIncomplete, pending)
+	}
+
+	result, err := db.Exec(`UPDATE student_task_scores SET grading_complete = 1 WHERE task_id = ? AND student_id = ?`, taskId, studentId)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrSubmissionNotFound
+	}
+
+	logger.Info("完成评分成功")
+	return nil
+}
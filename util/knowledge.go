@@ -0,0 +1,279 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// ErrKnowledgePointNotFound 知识点不存在
+var ErrKnowledgePointNotFound = errors.New("找不到知识点")
+
+// ErrKnowledgeTooDeep 知识点层级超过章、节、知识点三级
+var ErrKnowledgeTooDeep = errors.New("知识点最多三级")
+
+// 知识点层级
+const (
+	KnowledgeChapter = "chapter"
+	KnowledgeSection = "section"
+	KnowledgePoint   = "point"
+)
+
+// knowledgeChildLevel 各层级下一级的层级
+var knowledgeChildLevel = map[string]string{
+	"":               KnowledgeChapter,
+	KnowledgeChapter: KnowledgeSection,
+	KnowledgeSection: KnowledgePoint,
+}
+
+// KnowledgeNode 知识点目录中的节点
+type KnowledgeNode struct {
+	KpId       string           `json:"kp_id"`
+	ParentId   string           `json:"parent_id"`
+	Name       string           `json:"name"`
+	Level      string           `json:"level"`
+	CreateTime string           `json:"create_time"`
+	Children   []*KnowledgeNode `json:"children"`
+}
+
+// AddKnowledgePoint 添加知识点 parentId为空时添加章 层级由上级决定
+func AddKnowledgePoint(name string, parentId string) (*KnowledgeNode, error) {
+	logger, _ := NewLogger()
+
+	parentLevel := ""
+	if parentId != "" {
+		err := db.QueryRow(`SELECT level FROM knowledge_points WHERE kp_id = ?`, parentId).Scan(&parentLevel)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrKnowledgePointNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	level, ok := knowledgeChildLevel[parentLevel]
+	if !ok {
+		return nil, ErrKnowledgeTooDeep
+	}
+
+	node := &KnowledgeNode{
+		KpId:       uuid.NewV4().String(),
+		ParentId:   parentId,
+		Name:       name,
+		Level:      level,
+		CreateTime: time.Now().Format("2006-01-02 15:04:05.000"),
+		Children:   []*KnowledgeNode{},
+	}
+	_, err := db.Exec(`INSERT INTO knowledge_points (kp_id, parent_id, name, level, create_time) VALUES (?, ?, ?, ?, ?)`,
+		node.KpId, node.ParentId, node.Name, node.Level, node.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("添加知识点成功")
+	return node, nil
+}
+
+// loadKnowledgePoints 读取全部知识点 按ID索引
+func loadKnowledgePoints() (map[string]*KnowledgeNode, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT kp_id, parent_id, name, level, create_time FROM knowledge_points ORDER BY create_time, kp_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	nodes := make(map[string]*KnowledgeNode)
+	for rows.Next() {
+		node := &KnowledgeNode{Children: []*KnowledgeNode{}}
+		if err = rows.Scan(&node.KpId, &node.ParentId, &node.Name, &node.Level, &node.CreateTime); err != nil {
+			return nil, err
+		}
+		nodes[node.KpId] = node
+	}
+	return nodes, rows.Err()
+}
+
+// GetKnowledgeTree 获取知识点目录树
+func GetKnowledgeTree() ([]*KnowledgeNode, error) {
+	nodes, err := loadKnowledgePoints()
+	if err != nil {
+		return nil, err
+	}
+
+	roots := []*KnowledgeNode{}
+	for _, node := range nodes {
+		if parent, ok := nodes[node.ParentId]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	sortKnowledgeNodes(roots)
+	return roots, nil
+}
+
+// sortKnowledgeNodes 按创建时间排序目录树
+func sortKnowledgeNodes(nodes []*KnowledgeNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].CreateTime != nodes[j].CreateTime {
+			return nodes[i].CreateTime < nodes[j].CreateTime
+		}
+		return nodes[i].KpId < nodes[j].KpId
+	})
+	for _, node := range nodes {
+		sortKnowledgeNodes(node.Children)
+	}
+}
+
+// setQuestionKnowledgePoints 替换题目关联的知识点 可在事务中调用
+func setQuestionKnowledgePoints(ex dbExecutor, qaId string, kpIds []string) error {
+	if _, err := ex.Exec(`DELETE FROM question_knowledge_points WHERE qa_id = ?`, qaId); err != nil {
+		return err
+	}
+	for _, kpId := range kpIds {
+		var count int
+		if err := ex.QueryRow(`SELECT COUNT(*) FROM knowledge_points WHERE kp_id = ?`, kpId).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %s", ErrKnowledgePointNotFound, kpId)
+		}
+		if _, err := ex.Exec(`INSERT OR IGNORE INTO question_knowledge_points (qa_id, kp_id) VALUES (?, ?)`, qaId, kpId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetQuestionKnowledgePoints 替换题目关联的知识点
+func SetQuestionKnowledgePoints(qaId string, kpIds []string) error {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	if err = setQuestionKnowledgePoints(tx, qaId, kpIds); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Info("设置题目知识点成功")
+	return nil
+}
+
+// KnowledgeMastery 学生对某个知识点的掌握情况 章、节包含其下所有知识点的作答
+type KnowledgeMastery struct {
+	KpId     string `json:"kp_id"`
+	ParentId string `json:"parent_id"`
+	Name     string `json:"name"`
+	Level    string `json:"level"`
+	// Path 从章到该知识点的名称 以/分隔
+	Path         string  `json:"path"`
+	AnswerCount  int     `json:"answer_count"`
+	CorrectCount int     `json:"correct_count"`
+	Score        float64 `json:"score"`
+	Points       float64 `json:"points"`
+	// MasteryRate 得分占分值的比例
+	MasteryRate float64 `json:"mastery_rate"`
+}
+
+// GetStudentMastery 按知识点汇总学生在所有已提交任务中的作答情况 多次作答时只统计计入成绩的作答
+// start、end不为空时只统计提交时间在该范围内的任务
+func GetStudentMastery(studentId string, start string, end string) ([]KnowledgeMastery, error) {
+	logger, _ := NewLogger()
+
+	nodes, err := loadKnowledgePoints()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT qkp.kp_id, sta.is_correct, sta.score, td.points
+		FROM student_task_answers sta
+		INNER JOIN student_task_scores sts ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+		INNER JOIN task_time tt ON tt.student_id = sta.student_id AND tt.task_id = sta.task_id AND tt.attempt = sta.attempt
+		INNER JOIN task_data td ON td.qa_id = sta.qa_id
+		INNER JOIN question_knowledge_points qkp ON qkp.qa_id = sta.qa_id
+		WHERE sta.student_id = ?1 AND tt.push_answer_time != ''
+			AND (?2 = '' OR tt.push_answer_time >= ?2) AND (?3 = '' OR tt.push_answer_time <= ?3)`, studentId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	masteries := make(map[string]*KnowledgeMastery)
+	for rows.Next() {
+		var kpId string
+		var isCorrect bool
+		var score, points float64
+		if err = rows.Scan(&kpId, &isCorrect, &score, &points); err != nil {
+			return nil, err
+		}
+		// 计入该知识点及其所有上级
+		for node, ok := nodes[kpId]; ok; node, ok = nodes[node.ParentId] {
+			mastery, exists := masteries[node.KpId]
+			if !exists {
+				mastery = &KnowledgeMastery{
+					KpId:     node.KpId,
+					ParentId: node.ParentId,
+					Name:     node.Name,
+					Level:    node.Level,
+					Path:     knowledgePath(nodes, node),
+				}
+				masteries[node.KpId] = mastery
+			}
+			mastery.AnswerCount++
+			if isCorrect {
+				mastery.CorrectCount++
+			}
+			mastery.Score += score
+			mastery.Points += points
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := []KnowledgeMastery{}
+	for _, mastery := range masteries {
+		if mastery.Points > 0 {
+			mastery.MasteryRate = mastery.Score / mastery.Points
+		}
+		result = append(result, *mastery)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+
+	logger.Info("获取知识点掌握情况成功")
+	return result, nil
+}
+
+// knowledgePath 从章到该知识点的名称路径
+func knowledgePath(nodes map[string]*KnowledgeNode, node *KnowledgeNode) string {
+	var names []string
+	for current, ok := node, true; ok; current, ok = nodes[current.ParentId] {
+		names = append([]string{current.Name}, names...)
+	}
+	return strings.Join(names, "/")
+}
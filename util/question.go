@@ -0,0 +1,260 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 题型
+const (
+	// QuestionSingle 单选题
+	QuestionSingle = "single"
+	// QuestionMultiple 多选题
+	QuestionMultiple = "multiple"
+	// QuestionJudge 判断题
+	QuestionJudge = "judge"
+	// QuestionBlank 填空题
+	QuestionBlank = "blank"
+	// QuestionNumeric 数值题
+	QuestionNumeric = "numeric"
+	// QuestionEssay 主观题 不自动评分
+	QuestionEssay = "essay"
+)
+
+// DefaultOptionCount 未指定选项时的默认选项数量
+const DefaultOptionCount = 4
+
+// 多选题部分得分规则
+const (
+	// PartialNone 全对得满分 否则不得分
+	PartialNone = "none"
+	// PartialHalf 全对得满分 少选且无错选得一半分 有错选不得分
+	PartialHalf = "half"
+	// PartialProportional 按选对的比例得分 有错选不得分
+	PartialProportional = "proportional"
+)
+
+// ErrInvalidQuestion 题目设置错误
+var ErrInvalidQuestion = errors.New("题目设置错误")
+
+// duplicateQaNumber 检查题号是否已出现过 seen记录每个题号首次出现的位置
+// 重复时返回首次出现的位置 否则记录本次位置
+func duplicateQaNumber(seen map[int]int, qaNumber int, position int) (first int, duplicated bool) {
+	if first, ok := seen[qaNumber]; ok {
+		return first, true
+	}
+	seen[qaNumber] = position
+	return 0, false
+}
+
+// QuestionConfig 题目的附加设置 以JSON保存在task_data.q_config
+type QuestionConfig struct {
+	// Options 选择题的选项内容 按顺序对应A、B、C...
+	Options []string `json:"options,omitempty"`
+	// AcceptedAnswers 填空题可接受的其他答案
+	AcceptedAnswers []string `json:"accepted_answers,omitempty"`
+	// Tolerance 数值题允许的误差
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// PartialCredit 多选题部分得分规则 为空时全对才得分
+	PartialCredit string `json:"partial_credit,omitempty"`
+}
+
+// judgeAnswers 判断题可识别的作答方式
+var judgeAnswers = map[string]string{
+	"T": "T", "TRUE": "T", "Y": "T", "YES": "T", "1": "T", "对": "T", "正确": "T", "√": "T",
+	"F": "F", "FALSE": "F", "N": "F", "NO": "F", "0": "F", "错": "F", "错误": "F", "×": "F",
+}
+
+// optionLetter 第i个选项的字母
+func optionLetter(i int) string {
+	return string(rune('A' + i))
+}
+
+// NewQuestionConfig 根据题目请求生成题目设置 并补全单选、多选题的选项
+func NewQuestionConfig(answer QAAnswer) QuestionConfig {
+	config := QuestionConfig{
+		Options:         answer.Options,
+		AcceptedAnswers: answer.AcceptedAnswers,
+		Tolerance:       answer.Tolerance,
+		PartialCredit:   answer.PartialCredit,
+	}
+	qType := answer.QaType
+	if (qType == "" || qType == QuestionSingle || qType == QuestionMultiple) && len(config.Options) == 0 {
+		count := answer.OptionCount
+		if count <= 0 {
+			count = DefaultOptionCount
+		}
+		for i := 0; i < count; i++ {
+			config.Options = append(config.Options, "这是答题卡，填写答案即可。")
+		}
+	}
+	return config
+}
+
+// ParseQuestionConfig 解析保存的题目设置 旧版本题目没有设置时使用默认值
+func ParseQuestionConfig(qType string, s string) QuestionConfig {
+	var config QuestionConfig
+	if s != "" {
+		if err := json.Unmarshal([]byte(s), &config); err != nil {
+			logger, _ := NewLogger()
+			logger.Error("解析题目设置失败: ", err)
+		}
+	}
+	if qType == QuestionSingle && len(config.Options) == 0 {
+		config = NewQuestionConfig(QAAnswer{QaType: qType})
+	}
+	return config
+}
+
+// String 题目设置的JSON文本
+func (config QuestionConfig) String() string {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Choices 学生端显示的选项 非选择题为空
+func (config QuestionConfig) Choices(qType string) map[string]string {
+	switch qType {
+	case QuestionSingle, QuestionMultiple:
+		choices := make(map[string]string)
+		for i, option := range config.Options {
+			choices[optionLetter(i)] = option
+		}
+		return choices
+	case QuestionJudge:
+		return map[string]string{"T": "正确", "F": "错误"}
+	}
+	return map[string]string{}
+}
+
+// normalizeChoices 将多选答案转为排序去重后的选项字母
+func normalizeChoices(answer string) string {
+	seen := make(map[rune]bool)
+	var letters []string
+	for _, r := range strings.ToUpper(answer) {
+		if r < 'A' || r > 'Z' || seen[r] {
+			continue
+		}
+		seen[r] = true
+		letters = append(letters, string(r))
+	}
+	sort.Strings(letters)
+	return strings.Join(letters, "")
+}
+
+// ValidateQuestion 校验题型、答案与设置是否匹配
+func ValidateQuestion(qType string, key string, config QuestionConfig) error {
+	switch qType {
+	case QuestionSingle, QuestionMultiple:
+		if len(config.Options) < 2 || len(config.Options) > 26 {
+			return fmt.Errorf("%w: 选项数量应在2到26之间", ErrInvalidQuestion)
+		}
+		normalized := NormalizeAnswer(key)
+		if qType == QuestionMultiple {
+			normalized = normalizeChoices(key)
+		}
+		if normalized == "" || (qType == QuestionSingle && len(normalized) != 1) {
+			return fmt.Errorf("%w: 选择题答案应为选项字母", ErrInvalidQuestion)
+		}
+		for _, r := range normalized {
+			if int(r-'A') >= len(config.Options) || r < 'A' {
+				return fmt.Errorf("%w: 答案 %s 超出选项范围", ErrInvalidQuestion, string(r))
+			}
+		}
+	case QuestionJudge:
+		if _, ok := judgeAnswers[NormalizeAnswer(key)]; !ok {
+			return fmt.Errorf("%w: 判断题答案应为T或F", ErrInvalidQuestion)
+		}
+	case QuestionNumeric:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(key), 64); err != nil {
+			return fmt.Errorf("%w: 数值题答案应为数字", ErrInvalidQuestion)
+		}
+		if config.Tolerance < 0 {
+			return fmt.Errorf("%w: 误差不能为负数", ErrInvalidQuestion)
+		}
+	case QuestionBlank, QuestionEssay:
+	default:
+		return fmt.Errorf("%w: 未知题型 %s", ErrInvalidQuestion, qType)
+	}
+	switch config.PartialCredit {
+	case "", PartialNone, PartialHalf, PartialProportional:
+	default:
+		return fmt.Errorf("%w: 未知部分得分规则 %s", ErrInvalidQuestion, config.PartialCredit)
+	}
+	return nil
+}
+
+// gradeMultiple 按部分得分规则计算多选题的得分比例
+func gradeMultiple(key string, config QuestionConfig, answer string) float64 {
+	keyChoices := normalizeChoices(key)
+	stuChoices := normalizeChoices(answer)
+	if stuChoices == keyChoices {
+		return 1
+	}
+	if stuChoices == "" {
+		return 0
+	}
+	for _, r := range stuChoices {
+		if !strings.ContainsRune(keyChoices, r) {
+			// 有错选不得分
+			return 0
+		}
+	}
+	switch config.PartialCredit {
+	case PartialHalf:
+		return 0.5
+	case PartialProportional:
+		return float64(len(stuChoices)) / float64(len(keyChoices))
+	}
+	return 0
+}
+
+// GradeQuestion 按题型判断学生答案 返回得分比例(0到1)以及是否可以自动评分
+func GradeQuestion(qType string, key string, config QuestionConfig, answer string) (ratio float64, autoGraded bool) {
+	if strings.TrimSpace(answer) == "" {
+		return 0, qType != QuestionEssay
+	}
+	switch qType {
+	case QuestionMultiple:
+		return gradeMultiple(key, config, answer), true
+	case QuestionJudge:
+		stu, ok := judgeAnswers[NormalizeAnswer(answer)]
+		return boolRatio(ok && stu == judgeAnswers[NormalizeAnswer(key)]), true
+	case QuestionBlank:
+		for _, accepted := range append([]string{key}, config.AcceptedAnswers...) {
+			if strings.EqualFold(strings.TrimSpace(answer), strings.TrimSpace(accepted)) {
+				return 1, true
+			}
+		}
+		return 0, true
+	case QuestionNumeric:
+		stu, err := strconv.ParseFloat(strings.TrimSpace(answer), 64)
+		if err != nil {
+			return 0, true
+		}
+		tea, err := strconv.ParseFloat(strings.TrimSpace(key), 64)
+		if err != nil {
+			return 0, true
+		}
+		return boolRatio(math.Abs(stu-tea) <= config.Tolerance+1e-9), true
+	case QuestionEssay:
+		return 0, false
+	}
+	return boolRatio(JudgeAnswer(key, answer)), true
+}
+
+// boolRatio 对错转换为得分比例
+func boolRatio(correct bool) float64 {
+	if correct {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,361 @@
+package util
+
+import (
+	"ZhiShanYunXue/setting"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BankQuestion 题库中的题目
+type BankQuestion struct {
+	QaId       string         `json:"qa_id"`
+	QaTitle    string         `json:"qa_title"`
+	QaType     string         `json:"q_type"`
+	QaAnswer   string         `json:"qa_answer"`
+	Points     float64        `json:"points"`
+	Config     QuestionConfig `json:"config"`
+	Tags       []string       `json:"tags"`
+	OwnerId    string         `json:"owner_id"`
+	CreateTime string         `json:"create_time"`
+	// UsageCount 引用该题的任务数
+	UsageCount int `json:"usage_count"`
+}
+
+// BankQuery 题库查询条件
+type BankQuery struct {
+	// OwnerId 不为空时只返回该教师创建的题目
+	OwnerId  string
+	Keyword  string
+	Tag      string
+	QaType   string
+	Page     int
+	PageSize int
+}
+
+// BankQuestionList 题库分页查询结果
+type BankQuestionList struct {
+	Total     int            `json:"total"`
+	Page      int            `json:"page"`
+	PageSize  int            `json:"page_size"`
+	Questions []BankQuestion `json:"questions"`
+}
+
+// normalizeTags 去除标签中的空白与重复
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// setQuestionTags 替换题目的标签 可在事务中调用
+func setQuestionTags(ex dbExecutor, qaId string, tags []string) error {
+	if _, err := ex.Exec(`DELETE FROM question_tags WHERE qa_id = ?`, qaId); err != nil {
+		return err
+	}
+	for _, tag := range normalizeTags(tags) {
+		if _, err := ex.Exec(`INSERT INTO question_tags (qa_id, tag) VALUES (?, ?)`, qaId, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddBankQuestion 向题库添加题目 题目不属于任何任务
+func AddBankQuestion(ownerId string, answer QAAnswer, tags []string) (*BankQuestion, error) {
+	logger, _ := NewLogger()
+
+	if answer.QaType == "" {
+		answer.QaType = QuestionSingle
+	}
+	if answer.Points == 0 {
+		answer.Points = 1
+	}
+	if answer.Points < 0 {
+		return nil, fmt.Errorf("%w: 分值不能为负数", ErrInvalidQuestion)
+	}
+	config := NewQuestionConfig(answer)
+	if err := ValidateQuestion(answer.QaType, answer.QaAnswer, config); err != nil {
+		return nil, err
+	}
+
+	qaId := GenerateQaId(setting.MaxTries)
+	if qaId == "" {
+		return nil, fmt.Errorf("分配题目id失败")
+	}
+	question := &BankQuestion{
+		QaId:       qaId,
+		QaTitle:    answer.QaTitle,
+		QaType:     answer.QaType,
+		QaAnswer:   answer.QaAnswer,
+		Points:     answer.Points,
+		Config:     config,
+		Tags:       normalizeTags(tags),
+		OwnerId:    ownerId,
+		CreateTime: time.Now().Format("2006-01-02 15:04:05.000"),
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	_, err = tx.Exec(`INSERT INTO task_data (qa_id, q_title, qa_number, q_choice, q_type, q_config, points, in_bank, owner_id, create_time)
+		VALUES (?, ?, 0, ?, ?, ?, ?, 1, ?, ?)`,
+		question.QaId, question.QaTitle, question.QaAnswer, question.QaType, config.String(), question.Points, question.OwnerId, question.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	if err = setQuestionTags(tx, qaId, question.Tags); err != nil {
+		return nil, err
+	}
+	if err = setQuestionKnowledgePoints(tx, qaId, answer.KpIds); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("添加题库题目成功")
+	return question, nil
+}
+
+// SearchBankQuestions 分页搜索题库
+func SearchBankQuestions(query BankQuery) (*BankQuestionList, error) {
+	logger, _ := NewLogger()
+
+	conditions := []string{"td.in_bank = 1"}
+	var args []interface{}
+	if query.OwnerId != "" {
+		conditions = append(conditions, "td.owner_id = ?")
+		args = append(args, query.OwnerId)
+	}
+	if query.Keyword != "" {
+		conditions = append(conditions, `td.q_title LIKE ? ESCAPE '\'`)
+		args = append(args, containsPattern(query.Keyword))
+	}
+	if query.Tag != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM question_tags qt WHERE qt.qa_id = td.qa_id AND qt.tag = ?)")
+		args = append(args, query.Tag)
+	}
+	if query.QaType != "" {
+		conditions = append(conditions, "td.q_type = ?")
+		args = append(args, query.QaType)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+	list := &BankQuestionList{
+		Page:      query.Page,
+		PageSize:  query.PageSize,
+		Questions: []BankQuestion{},
+	}
+
+	err := db.QueryRow(`SELECT COUNT(*) FROM task_data td`+where, args...).Scan(&list.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT td.qa_id, COALESCE(td.q_title, ''), td.q_type, td.q_choice, td.q_config, td.points, td.owner_id, td.create_time,
+			(SELECT COUNT(*) FROM task_qa_relations tqr WHERE tqr.qa_id = td.qa_id)
+		FROM task_data td`+where+` ORDER BY td.create_time DESC, td.qa_id LIMIT ? OFFSET ?`,
+		append(args, query.PageSize, (query.Page-1)*query.PageSize)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var question BankQuestion
+		var qConfig string
+		err = rows.Scan(&question.QaId, &question.QaTitle, &question.QaType, &question.QaAnswer, &qConfig, &question.Points,
+			&question.OwnerId, &question.CreateTime, &question.UsageCount)
+		if err != nil {
+			return nil, err
+		}
+		question.Config = ParseQuestionConfig(question.QaType, qConfig)
+		list.Questions = append(list.Questions, question)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 补充标签
+	for i := range list.Questions {
+		list.Questions[i].Tags, err = GetQuestionTags(list.Questions[i].QaId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Info("搜索题库成功")
+	return list, nil
+}
+
+// GetQuestionTags 获取题目的标签
+func GetQuestionTags(qaId string) ([]string, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT tag FROM question_tags WHERE qa_id = ? ORDER BY tag`, qaId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err = rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// SetQuestionTags 替换题目的标签
+func SetQuestionTags(qaId string, tags []string) error {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	if err = setQuestionTags(tx, qaId, tags); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Info("设置题目标签成功")
+	return nil
+}
+
+// CheckQuestionOwner 检查用户是否有权管理题目
+// 管理员可管理所有题目 教师可管理自己创建的题库题目以及自己任务中的题目
+func CheckQuestionOwner(qaId string, userId string, role string) error {
+	var ownerId string
+	err := db.QueryRow(`SELECT owner_id FROM task_data WHERE qa_id = ?`, qaId).Scan(&ownerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrQuestionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if role == RoleAdmin || (role == RoleTeacher && ownerId == userId) {
+		return nil
+	}
+	if role == RoleTeacher {
+		var count int
+		err = db.QueryRow(`SELECT COUNT(*) FROM task_qa_relations tqr INNER JOIN tasks t ON t.task_id = tqr.task_id
+			WHERE tqr.qa_id = ? AND t.owner_id = ?`, qaId, userId).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+// QuestionTaskUsage 题目在某个任务中的使用情况
+type QuestionTaskUsage struct {
+	TaskId       string  `json:"task_id"`
+	TaskTitle    string  `json:"task_title"`
+	PublishTime  string  `json:"publish_time"`
+	QaNumber     int     `json:"qa_number"`
+	AnswerCount  int     `json:"answer_count"`
+	CorrectCount int     `json:"correct_count"`
+	CorrectRate  float64 `json:"correct_rate"`
+}
+
+// QuestionUsage 题目在所有任务中的使用统计
+type QuestionUsage struct {
+	QaId         string              `json:"qa_id"`
+	UsageCount   int                 `json:"usage_count"`
+	AnswerCount  int                 `json:"answer_count"`
+	CorrectCount int                 `json:"correct_count"`
+	CorrectRate  float64             `json:"correct_rate"`
+	Tasks        []QuestionTaskUsage `json:"tasks"`
+}
+
+// GetQuestionUsage 统计题目被哪些任务引用以及每个任务中的作答情况 多次作答时只统计计入成绩的作答
+func GetQuestionUsage(qaId string) (*QuestionUsage, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT t.task_id, t.task_title, t.publish_time, tqr.qa_number,
+			(SELECT COUNT(*) FROM student_task_answers sta INNER JOIN student_task_scores sts
+				ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+				WHERE sta.task_id = t.task_id AND sta.qa_id = tqr.qa_id),
+			(SELECT COUNT(*) FROM student_task_answers sta INNER JOIN student_task_scores sts
+				ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+				WHERE sta.task_id = t.task_id AND sta.qa_id = tqr.qa_id AND sta.is_correct = 1)
+		FROM task_qa_relations tqr INNER JOIN tasks t ON t.task_id = tqr.task_id
+		WHERE tqr.qa_id = ? ORDER BY t.publish_time`, qaId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	usage := &QuestionUsage{QaId: qaId, Tasks: []QuestionTaskUsage{}}
+	for rows.Next() {
+		var taskUsage QuestionTaskUsage
+		err = rows.Scan(&taskUsage.TaskId, &taskUsage.TaskTitle, &taskUsage.PublishTime, &taskUsage.QaNumber,
+			&taskUsage.AnswerCount, &taskUsage.CorrectCount)
+		if err != nil {
+			return nil, err
+		}
+		if taskUsage.AnswerCount > 0 {
+			taskUsage.CorrectRate = float64(taskUsage.CorrectCount) / float64(taskUsage.AnswerCount)
+		}
+		usage.AnswerCount += taskUsage.AnswerCount
+		usage.CorrectCount += taskUsage.CorrectCount
+		usage.Tasks = append(usage.Tasks, taskUsage)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	usage.UsageCount = len(usage.Tasks)
+	if usage.AnswerCount > 0 {
+		usage.CorrectRate = float64(usage.CorrectCount) / float64(usage.AnswerCount)
+	}
+
+	logger.Info("获取题目使用统计成功")
+	return usage, nil
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGradeQuestion(t *testing.T) {
+	tests := []struct {
+		name       string
+		qType      string
+		key        string
+		config     QuestionConfig
+		answer     string
+		wantRatio  float64
+		wantGraded bool
+	}{
+		{"单选正确", QuestionSingle, "A", QuestionConfig{}, "a", 1, true},
+		{"单选错误", QuestionSingle, "A", QuestionConfig{}, "B", 0, true},
+		{"单选未作答", QuestionSingle, "A", QuestionConfig{}, " ", 0, true},
+		{"旧版本题目无题型", "", "C", QuestionConfig{}, "c", 1, true},
+		{"多选全对 顺序无关", QuestionMultiple, "AC", QuestionConfig{}, "ca", 1, true},
+		{"多选少选 默认不得分", QuestionMultiple, "AC", QuestionConfig{}, "A", 0, true},
+		{"判断 T与对等价", QuestionJudge, "T", QuestionConfig{}, "对", 1, true},
+		{"判断 false与F等价", QuestionJudge, "F", QuestionConfig{}, "false", 1, true},
+		{"判断错误", QuestionJudge, "T", QuestionConfig{}, "F", 0, true},
+		{"判断无法识别", QuestionJudge, "T", QuestionConfig{}, "maybe", 0, true},
+		{"填空忽略大小写与首尾空白", QuestionBlank, "Go", QuestionConfig{}, " go ", 1, true},
+		{"填空可接受答案", QuestionBlank, "北京", QuestionConfig{AcceptedAnswers: []string{"Beijing"}}, "beijing", 1, true},
+		{"填空错误", QuestionBlank, "北京", QuestionConfig{}, "上海", 0, true},
+		{"数值精确", QuestionNumeric, "3.14", QuestionConfig{}, "3.14", 1, true},
+		{"数值误差内", QuestionNumeric, "3.14", QuestionConfig{Tolerance: 0.01}, "3.15", 1, true},
+		{"数值误差外", QuestionNumeric, "3.14", QuestionConfig{Tolerance: 0.01}, "3.16", 0, true},
+		{"数值无法解析", QuestionNumeric, "3.14", QuestionConfig{}, "pi", 0, true},
+		{"主观题不自动评分", QuestionEssay, "", QuestionConfig{}, "答案", 0, false},
+		{"主观题未作答", QuestionEssay, "", QuestionConfig{}, "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ratio, graded := GradeQuestion(tt.qType, tt.key, tt.config, tt.answer)
+			if math.Abs(ratio-tt.wantRatio) > 1e-9 || graded != tt.wantGraded {
+				t.Errorf("GradeQuestion(%q, %q, %q) = (%v, %v), want (%v, %v)",
+					tt.qType, tt.key, tt.answer, ratio, graded, tt.wantRatio, tt.wantGraded)
+			}
+		})
+	}
+}
+
+func TestGradeMultiplePartialCredit(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial string
+		key     string
+		answer  string
+		want    float64
+	}{
+		{"none 全对", PartialNone, "ABC", "CBA", 1},
+		{"none 少选", PartialNone, "ABC", "AB", 0},
+		{"half 全对", PartialHalf, "ABC", "ABC", 1},
+		{"half 少选", PartialHalf, "ABC", "A", 0.5},
+		{"half 错选", PartialHalf, "ABC", "AD", 0},
+		{"half 未作答", PartialHalf, "ABC", "", 0},
+		{"proportional 全对", PartialProportional, "ABCD", "DCBA", 1},
+		{"proportional 少选", PartialProportional, "ABCD", "AB", 0.5},
+		{"proportional 少选一项", PartialProportional, "ABC", "AC", 2.0 / 3.0},
+		{"proportional 错选", PartialProportional, "ABC", "ABD", 0},
+		{"proportional 重复选项只计一次", PartialProportional, "ABCD", "AAB", 0.5},
+		{"空规则视为none", "", "AB", "A", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, graded := GradeQuestion(QuestionMultiple, tt.key, QuestionConfig{PartialCredit: tt.partial}, tt.answer)
+			if !graded || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("GradeQuestion(multiple, %q, %s, %q) = (%v, %v), want %v", tt.key, tt.partial, tt.answer, got, graded, tt.want)
+			}
+		})
+	}
+}
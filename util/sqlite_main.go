@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	uuid "github.com/satori/go.uuid"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -17,21 +19,45 @@ type QAAnswer struct {
 	QaTitle  string `json:"qa_title" binding:"required"`
 	QaNumber int    `json:"qa_number" binding:"required"`
 	QaAnswer string `json:"qa_answer" binding:"required"`
+	// QaType 题型 为空时为单选题
+	QaType string `json:"qa_type"`
+	// Options 选择题的选项内容 按顺序对应A、B、C...
+	Options []string `json:"options"`
+	// OptionCount 未提供选项内容时的选项数量 默认4个
+	OptionCount int `json:"option_count"`
+	// AcceptedAnswers 填空题可接受的其他答案
+	AcceptedAnswers []string `json:"accepted_answers"`
+	// Tolerance 数值题允许的误差
+	Tolerance float64 `json:"tolerance"`
+	// Points 本题分值 默认1分
+	Points float64 `json:"points"`
+	// PartialCredit 多选题部分得分规则 none、half或proportional
+	PartialCredit string `json:"partial_credit"`
+	// KpIds 题目考查的知识点
+	KpIds []string `json:"kp_ids"`
 }
 
 var (
 	db *sql.DB
 )
 
+// dbExecutor *sql.DB 与 *sql.Tx 的公共方法 便于同一段逻辑在事务内外复用
+type dbExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 // 初始化
 func init() {
 	// 打开数据库
 	logger, _ := NewLogger()
 	var err error
-	db, err = sql.Open(setting.DbDriverName, setting.DbName)
+	db, err = sql.Open(setting.DbDriverName, fmt.Sprintf("%s?_busy_timeout=%d", setting.DbName, setting.DbBusyTimeout))
 	if err != nil {
 		// 处理错误
-		logger.Error("打开数据库失败: %v", err)
+		logger.Errorf("打开数据库失败: %v", err)
 		return
 	}
 
@@ -44,36 +70,244 @@ func InitSqlite() {
 	// 创建任务表
 	if err := CreateTable(db, "tasks"); err != nil {
 		// 处理错误
-		logger.Error("创建任务表错误: %v", err)
+		logger.Errorf("创建任务表错误: %v", err)
 		return
 	}
 	// 创建任务数据表
 	if err := CreateTable(db, "task_data"); err != nil {
 		// 处理错误
-		logger.Error("创建任务数据表错误: %v", err)
+		logger.Errorf("创建任务数据表错误: %v", err)
 		return
 	}
 	// 创建任务和题目关联表
 	if err := CreateTable(db, "task_qa_relations"); err != nil {
 		// 处理错误
-		logger.Error("创建任务和题目关联表错误: %v", err)
+		logger.Errorf("创建任务和题目关联表错误: %v", err)
+		return
+	}
+	// 创建题目标签表
+	if err := CreateTable(db, "question_tags"); err != nil {
+		// 处理错误
+		logger.Errorf("创建题目标签表错误: %v", err)
+		return
+	}
+	// 创建知识点表
+	if err := CreateTable(db, "knowledge_points"); err != nil {
+		// 处理错误
+		logger.Errorf("创建知识点表错误: %v", err)
+		return
+	}
+	// 创建题目与知识点关联表
+	if err := CreateTable(db, "question_knowledge_points"); err != nil {
+		// 处理错误
+		logger.Errorf("创建题目与知识点关联表错误: %v", err)
 		return
 	}
 	// 创建学生和任务关联表
 	if err := CreateTable(db, "student_task_answers"); err != nil {
 		// 处理错误
-		logger.Error("创建学生和任务关联表错误: %v", err)
+		logger.Errorf("创建学生和任务关联表错误: %v", err)
+		return
+	}
+	// 创建错题复习表
+	if err := CreateTable(db, "wrong_question_reviews"); err != nil {
+		// 处理错误
+		logger.Errorf("创建错题复习表错误: %v", err)
+		return
+	}
+	// 创建答案草稿表
+	if err := CreateTable(db, "answer_drafts"); err != nil {
+		// 处理错误
+		logger.Errorf("创建答案草稿表错误: %v", err)
 		return
 	}
 	// 创建任务时间表
 	if err := CreateTable(db, "task_time"); err != nil {
 		// 处理错误
-		logger.Error("创建任务时间表错误: %v", err)
+		logger.Errorf("创建任务时间表错误: %v", err)
+		return
+	}
+	// 创建学生任务得分表
+	if err := CreateTable(db, "student_task_scores"); err != nil {
+		// 处理错误
+		logger.Errorf("创建学生任务得分表错误: %v", err)
+		return
+	}
+
+	// 创建学生账号表
+	if err := CreateTable(db, "students"); err != nil {
+		// 处理错误
+		logger.Errorf("创建学生账号表错误: %v", err)
+		return
+	}
+	// 创建登录会话表
+	if err := CreateTable(db, "sessions"); err != nil {
+		// 处理错误
+		logger.Errorf("创建登录会话表错误: %v", err)
+		return
+	}
+
+	// 创建教师账号表
+	if err := CreateTable(db, "teachers"); err != nil {
+		// 处理错误
+		logger.Errorf("创建教师账号表错误: %v", err)
+		return
+	}
+
+	// 创建班级表
+	if err := CreateTable(db, "classes"); err != nil {
+		// 处理错误
+		logger.Errorf("创建班级表错误: %v", err)
+		return
+	}
+	// 创建班级成员表
+	if err := CreateTable(db, "class_members"); err != nil {
+		// 处理错误
+		logger.Errorf("创建班级成员表错误: %v", err)
+		return
+	}
+	// 创建任务和班级关联表
+	if err := CreateTable(db, "task_classes"); err != nil {
+		// 处理错误
+		logger.Errorf("创建任务和班级关联表错误: %v", err)
+		return
+	}
+
+	// 旧版本数据库补充评分字段
+	if err := AddColumnIfNotExists(db, "student_task_answers", "is_correct", "INT not null default 0"); err != nil {
+		logger.Errorf("补充评分字段错误: %v", err)
+		return
+	}
+	if err := AddColumnIfNotExists(db, "student_task_answers", "score", "REAL not null default 0"); err != nil {
+		logger.Errorf("补充评分字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充迟交字段
+	if err := AddColumnIfNotExists(db, "tasks", "allow_late", "INT not null default 0"); err != nil {
+		logger.Errorf("补充迟交字段错误: %v", err)
+		return
+	}
+	if err := AddColumnIfNotExists(db, "task_time", "is_late", "int not null default 0"); err != nil {
+		logger.Errorf("补充迟交字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充任务所有者字段
+	if err := AddColumnIfNotExists(db, "tasks", "owner_id", "TEXT not null default ''"); err != nil {
+		logger.Errorf("补充任务所有者字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充答案公布字段
+	if err := AddColumnIfNotExists(db, "tasks", "answer_release", "TEXT not null default 'after_submit'"); err != nil {
+		logger.Errorf("补充答案公布字段错误: %v", err)
+		return
+	}
+	if err := AddColumnIfNotExists(db, "tasks", "answer_released", "INT not null default 0"); err != nil {
+		logger.Errorf("补充答案公布字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充题型字段
+	if err := AddColumnIfNotExists(db, "task_data", "q_type", "TEXT not null default 'single'"); err != nil {
+		logger.Errorf("补充题型字段错误: %v", err)
+		return
+	}
+	if err := AddColumnIfNotExists(db, "task_data", "q_config", "TEXT not null default ''"); err != nil {
+		logger.Errorf("补充题型字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充分值字段
+	if err := AddColumnIfNotExists(db, "task_data", "points", "REAL not null default 1"); err != nil {
+		logger.Errorf("补充分值字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充人工评分字段
+	manualGradingColumns := []struct{ table, column, definition string }{
+		{"student_task_answers", "needs_grading", "INT not null default 0"},
+		{"student_task_answers", "graded_by", "TEXT not null default ''"},
+		{"student_task_answers", "comment", "TEXT not null default ''"},
+		{"student_task_scores", "grading_complete", "INT not null default 1"},
+	}
+	for _, col := range manualGradingColumns {
+		if err := AddColumnIfNotExists(db, col.table, col.column, col.definition); err != nil {
+			logger.Errorf("补充人工评分字段错误: %v", err)
+			return
+		}
+	}
+	// 旧版本数据库补充题库字段 并将题号迁移到任务与题目关联表
+	questionBankColumns := []struct{ table, column, definition string }{
+		{"task_data", "in_bank", "INT not null default 0"},
+		{"task_data", "owner_id", "TEXT not null default ''"},
+		{"task_data", "create_time", "TEXT not null default ''"},
+		{"task_qa_relations", "qa_number", "INT not null default 0"},
+	}
+	for _, col := range questionBankColumns {
+		if err := AddColumnIfNotExists(db, col.table, col.column, col.definition); err != nil {
+			logger.Errorf("补充题库字段错误: %v", err)
+			return
+		}
+	}
+	if _, err := db.Exec(`UPDATE task_qa_relations SET qa_number = (SELECT td.qa_number FROM task_data td WHERE td.qa_id = task_qa_relations.qa_id)
+		WHERE qa_number = 0`); err != nil {
+		logger.Errorf("迁移题号错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充自学任务字段
+	if err := AddColumnIfNotExists(db, "tasks", "student_id", "TEXT not null default ''"); err != nil {
+		logger.Errorf("补充自学任务字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充多次作答字段 唯一约束需要重建表
+	attemptColumns := []struct{ table, column, definition string }{
+		{"tasks", "max_attempts", "INT not null default 1"},
+		{"tasks", "attempt_policy", "TEXT not null default 'highest'"},
+		{"student_task_scores", "attempt", "INT not null default 1"},
+	}
+	for _, col := range attemptColumns {
+		if err := AddColumnIfNotExists(db, col.table, col.column, col.definition); err != nil {
+			logger.Errorf("补充多次作答字段错误: %v", err)
+			return
+		}
+	}
+	for _, table := range []string{"student_task_answers", "task_time"} {
+		if err := rebuildTableIfColumnMissing(table, "attempt"); err != nil {
+			logger.Errorf("重建%s表错误: %v", table, err)
+			return
+		}
+	}
+	if _, err := db.Exec(`UPDATE task_time SET score = (SELECT COALESCE(SUM(sta.score), 0) FROM student_task_answers sta
+		WHERE sta.student_id = task_time.student_id AND sta.task_id = task_time.task_id AND sta.attempt = task_time.attempt)
+		WHERE score = 0 AND push_answer_time != ''`); err != nil {
+		logger.Errorf("迁移作答得分错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充学生激活码字段
+	if err := AddColumnIfNotExists(db, "students", "activation_code_hash", "TEXT not null default ''"); err != nil {
+		logger.Errorf("补充学生激活码字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充强制修改密码字段
+	if err := AddColumnIfNotExists(db, "teachers", "must_change_password", "INT not null default 0"); err != nil {
+		logger.Errorf("补充强制修改密码字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充逐题用时字段
+	if err := AddColumnIfNotExists(db, "student_task_answers", "spend_time", "TEXT not null default ''"); err != nil {
+		logger.Errorf("补充逐题用时字段错误: %v", err)
+		return
+	}
+	// 旧版本数据库补充答题时限字段
+	if err := AddColumnIfNotExists(db, "tasks", "time_limit", "INT not null default 0"); err != nil {
+		logger.Errorf("补充答题时限字段错误: %v", err)
+		return
+	}
+
+	// 创建默认管理员
+	if err := InitAdmin(); err != nil {
+		logger.Errorf("创建默认管理员错误: %v", err)
 	}
 }
 
-// CreateTable 创建表
-func CreateTable(db *sql.DB, table string) error {
+// CreateTable 创建表 可在事务中调用
+func CreateTable(ex dbExecutor, table string) error {
 	// 声明变量
 	var s string
 	switch table {
@@ -85,7 +319,15 @@ func CreateTable(db *sql.DB, table string) error {
 			task_title       TEXT not null,
 			task_description TEXT not null,
 			publish_time     TEXT not null,
-			Deadline         TEXT not null
+			Deadline         TEXT not null,
+			allow_late       INT  not null default 0,
+			owner_id         TEXT not null default '',
+			answer_release   TEXT not null default 'after_submit',
+			answer_released  INT  not null default 0,
+			max_attempts     INT  not null default 1,         -- 最大提交次数 0表示不限
+			attempt_policy   TEXT not null default 'highest', -- 多次提交的计分方式
+			student_id       TEXT not null default '', -- 错题练习等自学任务所属的学生 为空表示教师布置的任务
+			time_limit       INT  not null default 0   -- 答题时限(秒) 0表示不限时
 		)`
 
 	case "task_data":
@@ -95,7 +337,13 @@ func CreateTable(db *sql.DB, table string) error {
 			qa_id    TEXT not null,
 			q_title  TEXT,
 			qa_number INT not null,
-			q_choice TEXT not null
+			q_choice TEXT not null,
+			q_type   TEXT not null default 'single',
+			q_config TEXT not null default '',
+			points   REAL not null default 1,
+			in_bank  INT  not null default 0,  -- 是否在题库中 题库题目可被多个任务引用
+			owner_id TEXT not null default '', -- 题库题目的创建者
+			create_time TEXT not null default ''
 		)`
 
 	case "task_qa_relations":
@@ -106,15 +354,71 @@ func CreateTable(db *sql.DB, table string) error {
 				references tasks (task_id),
 			qa_id   TEXT not null
 				references task_data (qa_id),
+			qa_number INT not null default 0, -- 题目在该任务中的题号
 			primary key (task_id, qa_id)
 		)`
+	case "knowledge_points":
+		// 知识点目录 章、节、知识点三级
+		s = `create table if not exists knowledge_points
+		(
+			kp_id       TEXT not null primary key,
+			parent_id   TEXT not null default '',
+			name        TEXT not null,
+			level       TEXT not null,
+			create_time TEXT not null
+		)`
+	case "question_knowledge_points":
+		// 题目与知识点关联表
+		s = `create table if not exists question_knowledge_points
+		(
+			qa_id TEXT not null,
+			kp_id TEXT not null,
+			primary key (qa_id, kp_id)
+		)`
+	case "question_tags":
+		// 题目标签
+		s = `create table if not exists question_tags
+		(
+			qa_id TEXT not null,
+			tag   TEXT not null,
+			primary key (qa_id, tag)
+		)`
 	case "student_task_answers":
 		s = `create table if not exists student_task_answers (
-		student_id TEXT not null, -- 学生id 暂时使用5位学号代替
+		student_id TEXT not null, -- 学生id 对应students表的学号
 		task_id TEXT not null,   -- 任务ID
 		qa_id TEXT not null,     -- 问题ID
 		answer TEXT not null,    -- 学生的答案
-		UNIQUE (student_id, task_id, qa_id) -- 确保组合唯一，避免同一学生在同一任务中对同一问题重复作答
+		is_correct INT not null default 0, -- 是否正确
+		score REAL not null default 0,     -- 本题得分
+		needs_grading INT not null default 0, -- 是否等待教师人工评分
+		graded_by TEXT not null default '',   -- 人工评分的教师 为空表示自动评分
+		comment TEXT not null default '',     -- 教师评语
+		attempt INT not null default 1,       -- 第几次作答
+		spend_time TEXT not null default '',  -- 本题用时(秒) 由客户端上报 为空表示没有记录
+		UNIQUE (student_id, task_id, qa_id, attempt) -- 确保组合唯一，避免同一学生在同一次作答中对同一问题重复作答
+		)`
+	case "wrong_question_reviews":
+		// 学生已复习的错题
+		s = `create table if not exists wrong_question_reviews
+		(
+			student_id  TEXT not null,
+			task_id     TEXT not null,
+			qa_id       TEXT not null,
+			review_time TEXT not null,
+			primary key (student_id, task_id, qa_id)
+		)`
+	case "answer_drafts":
+		// 学生尚未提交的答案草稿 提交时合并到student_task_answers
+		s = `create table if not exists answer_drafts
+		(
+			student_id TEXT not null,
+			task_id    TEXT not null,
+			qa_id      TEXT not null,
+			answer     TEXT not null,
+			spend_time TEXT not null default '',
+			save_time  TEXT not null,
+			primary key (student_id, task_id, qa_id)
 		)`
 	case "task_time":
 		s = `create table if not exists task_time
@@ -123,21 +427,190 @@ func CreateTable(db *sql.DB, table string) error {
 			task_id          text not null,
 			get_task_time    text not null,
 			push_answer_time text not null,
+			is_late          int  not null default 0,
+			attempt          int  not null default 1, -- 第几次作答 每次作答一条记录
+			score            real not null default 0, -- 本次作答的得分
+			unique (student_id, task_id, attempt)
+		)`
+	case "student_task_scores":
+		// 学生任务得分
+		s = `create table if not exists student_task_scores
+		(
+			student_id  TEXT not null,
+			task_id     TEXT not null,
+			score       REAL not null,
+			full_score  REAL not null,
+			graded_time TEXT not null,
+			grading_complete INT not null default 1,
+			attempt     INT  not null default 1, -- 计入成绩的作答
 			unique (student_id, task_id)
 		)`
+	case "students":
+		// 学生账号
+		s = `create table if not exists students
+		(
+			student_id    TEXT not null primary key,
+			student_name  TEXT not null,
+			password_hash TEXT not null,               -- 为空表示尚未激活
+			create_time   TEXT not null,
+			activation_code_hash TEXT not null default '' -- 教师发放的一次性激活码 激活后清空
+		)`
+	case "sessions":
+		// 登录会话
+		s = `create table if not exists sessions
+		(
+			token       TEXT not null primary key,
+			user_id     TEXT not null,
+			role        TEXT not null,
+			create_time TEXT not null,
+			expire_time TEXT not null
+		)`
+	case "teachers":
+		// 教师及管理员账号
+		s = `create table if not exists teachers
+		(
+			teacher_id    TEXT not null primary key,
+			username      TEXT not null unique,
+			teacher_name  TEXT not null,
+			password_hash TEXT not null,
+			role          TEXT not null,
+			create_time   TEXT not null,
+			must_change_password INT not null default 0 -- 登录后需先修改初始密码
+		)`
+	case "classes":
+		// 班级
+		s = `create table if not exists classes
+		(
+			class_id    TEXT not null primary key,
+			class_name  TEXT not null,
+			owner_id    TEXT not null,
+			create_time TEXT not null
+		)`
+	case "class_members":
+		// 班级成员
+		s = `create table if not exists class_members
+		(
+			class_id   TEXT not null
+				references classes (class_id),
+			student_id TEXT not null,
+			join_time  TEXT not null,
+			primary key (class_id, student_id)
+		)`
+	case "task_classes":
+		// 任务布置的班级
+		s = `create table if not exists task_classes
+		(
+			task_id  TEXT not null
+				references tasks (task_id),
+			class_id TEXT not null
+				references classes (class_id),
+			primary key (task_id, class_id)
+		)`
 	}
 	// 写入数据库
-	_, err := db.Exec(s)
+	_, err := ex.Exec(s)
 	return err
 }
 
+// AddColumnIfNotExists 为旧版本数据库补充字段
+func AddColumnIfNotExists(db *sql.DB, table string, column string, definition string) error {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	for _, name := range columns {
+		if name == column {
+			return nil
+		}
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// tableColumns 获取表的字段名
+func tableColumns(ex dbExecutor, table string) ([]string, error) {
+	logger, _ := NewLogger()
+
+	rows, err := ex.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err = rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// rebuildTableIfColumnMissing 旧表缺少字段时按当前表结构重建并复制原有数据
+// 用于修改唯一约束等 ALTER TABLE 无法完成的变更
+func rebuildTableIfColumnMissing(table string, column string) error {
+	logger, _ := NewLogger()
+
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	for _, name := range columns {
+		if name == column {
+			return nil
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	oldTable := table + "_old"
+	if _, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", table, oldTable)); err != nil {
+		return err
+	}
+	if err = CreateTable(tx, table); err != nil {
+		return err
+	}
+	columnList := strings.Join(columns, ", ")
+	if _, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", table, columnList, columnList, oldTable)); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(fmt.Sprintf("DROP TABLE %s", oldTable)); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Infof("重建%s表成功", table)
+	return nil
+}
+
 // CheckFieldValueExist 检查字段值是否存在
 func CheckFieldValueExist(table string, field string, fieldValue string) bool {
+	return checkFieldValueExist(db, table, field, fieldValue)
+}
+
+// checkFieldValueExist 检查字段值是否存在 可在事务中调用
+func checkFieldValueExist(ex dbExecutor, table string, field string, fieldValue string) bool {
 	// 定义日志
 	logger, _ := NewLogger()
 	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", table, field)
 	var count int
-	err := db.QueryRow(query, fieldValue).Scan(&count)
+	err := ex.QueryRow(query, fieldValue).Scan(&count)
 	if err != nil {
 		logger.Error("查询字段失败 ", err)
 		return false
@@ -187,12 +660,43 @@ func GenerateQaId(maxTries int) string {
 	return ""
 }
 
+// TaskSetting 任务设置
+type TaskSetting struct {
+	// AllowLate 截止后是否允许迟交
+	AllowLate bool `json:"allow_late"`
+	// AnswerRelease 答案公布策略 为空时默认提交后公布
+	AnswerRelease string `json:"answer_release"`
+	// ClassIds 布置的班级 为空时对所有学生开放
+	ClassIds []string `json:"class_ids"`
+	// MaxAttempts 最大提交次数 0表示不限
+	MaxAttempts int `json:"max_attempts"`
+	// AttemptPolicy 多次提交的计分方式 为空时取最高分
+	AttemptPolicy string `json:"attempt_policy"`
+	// TimeLimit 每次作答的时限(秒) 0表示不限时
+	TimeLimit int `json:"time_limit"`
+}
+
 // AddTask 添加任务
-func AddTask(taskId, taskTitle, taskDescription, deadline string, answers []QAAnswer) (success bool, err error) {
+// bankQaIds 为从题库选用的题目 按顺序编号在answers之后
+func AddTask(taskId, ownerId, taskTitle, taskDescription, deadline string, taskSetting TaskSetting, answers []QAAnswer, bankQaIds []string) (success bool, err error) {
 	logger, _ := NewLogger()
 
+	if taskSetting.AnswerRelease == "" {
+		taskSetting.AnswerRelease = ReleaseAfterSubmit
+	}
+	if taskSetting.AttemptPolicy == "" {
+		taskSetting.AttemptPolicy = AttemptHighest
+	}
+
+	// 所有写入在同一事务中完成 任一步失败则全部回滚
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer rollback(tx)
+
 	// 插入tasks数据库
-	taskStmt, err := db.Prepare(`INSERT INTO tasks (task_id, task_title, task_description, publish_time, Deadline) VALUES (?, ?, ?, ?, ?)`)
+	taskStmt, err := tx.Prepare(`INSERT INTO tasks (task_id, task_title, task_description, publish_time, Deadline, allow_late, owner_id, answer_release, max_attempts, attempt_policy, time_limit) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return false, err
 	}
@@ -202,16 +706,16 @@ func AddTask(taskId, taskTitle, taskDescription, deadline string, answers []QAAn
 			logger.Error(closeErr)
 		}
 	}()
-	_, err = taskStmt.Exec(taskId, taskTitle, taskDescription, time.Now().Format("2006-01-02 15:04:05.000"), deadline)
+	_, err = taskStmt.Exec(taskId, taskTitle, taskDescription, time.Now().Format("2006-01-02 15:04:05.000"), deadline, taskSetting.AllowLate, ownerId, taskSetting.AnswerRelease,
+		taskSetting.MaxAttempts, taskSetting.AttemptPolicy, taskSetting.TimeLimit)
 	if err != nil {
 		return false, err
 	}
-	if !CheckFieldValueExist("tasks", "task_id", taskId) {
+	if !checkFieldValueExist(tx, "tasks", "task_id", taskId) {
 		return false, fmt.Errorf("添加任务失败")
 	}
-	logger.Info("添加任务成功")
 
-	dataStmt, err := db.Prepare(`INSERT INTO task_data (qa_id, q_title, qa_number, q_choice) VALUES (?, ?, ?, ?)`)
+	dataStmt, err := tx.Prepare(`INSERT INTO task_data (qa_id, q_title, qa_number, q_choice, q_type, q_config, points) VALUES (?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return false, err
 	}
@@ -222,7 +726,7 @@ func AddTask(taskId, taskTitle, taskDescription, deadline string, answers []QAAn
 		}
 	}()
 
-	relationStmt, err := db.Prepare(`INSERT INTO task_qa_relations (task_id, qa_id) VALUES (?, ?)`)
+	relationStmt, err := tx.Prepare(`INSERT INTO task_qa_relations (task_id, qa_id, qa_number) VALUES (?, ?, ?)`)
 	if err != nil {
 		return false, err
 	}
@@ -233,31 +737,89 @@ func AddTask(taskId, taskTitle, taskDescription, deadline string, answers []QAAn
 		}
 	}()
 
-	for _, answer := range answers {
+	maxNumber := 0
+	seen := make(map[int]int, len(answers))
+	for i, answer := range answers {
+		// 题号须为正整数且不能重复 与导入答案表的校验一致
+		if answer.QaNumber <= 0 {
+			return false, fmt.Errorf("%w: 题号%d应为正整数", ErrInvalidQuestion, answer.QaNumber)
+		}
+		if first, duplicated := duplicateQaNumber(seen, answer.QaNumber, i+1); duplicated {
+			return false, fmt.Errorf("%w: 第%d个与第%d个题目的题号都是%d", ErrInvalidQuestion, first, i+1, answer.QaNumber)
+		}
+		if answer.QaNumber > maxNumber {
+			maxNumber = answer.QaNumber
+		}
+		// 校验题型与答案
+		if answer.QaType == "" {
+			answer.QaType = QuestionSingle
+		}
+		if answer.Points == 0 {
+			answer.Points = 1
+		}
+		if answer.Points < 0 {
+			return false, fmt.Errorf("第%d题 %w: 分值不能为负数", answer.QaNumber, ErrInvalidQuestion)
+		}
+		config := NewQuestionConfig(answer)
+		if err = ValidateQuestion(answer.QaType, answer.QaAnswer, config); err != nil {
+			return false, fmt.Errorf("第%d题 %w", answer.QaNumber, err)
+		}
+
 		// 在服务端为每个题目生成QaId(题目id)
 		QaId := GenerateQaId(setting.MaxTries)
+		if QaId == "" {
+			return false, fmt.Errorf("分配题目id失败")
+		}
 
 		// 插入task_data数据库
-		_, err = dataStmt.Exec(QaId, fmt.Sprintf(answer.QaTitle), answer.QaNumber, answer.QaAnswer)
+		_, err = dataStmt.Exec(QaId, fmt.Sprintf(answer.QaTitle), answer.QaNumber, answer.QaAnswer, answer.QaType, config.String(), answer.Points)
 		if err != nil {
 			return false, err
 		}
-		if !CheckFieldValueExist("task_data", "qa_id", QaId) {
+		if !checkFieldValueExist(tx, "task_data", "qa_id", QaId) {
 			return false, fmt.Errorf("添加题目失败")
 		}
-		logger.Infof("添加题目数据成功")
 
 		// 插入task_qa_relations关联表
-		_, err = relationStmt.Exec(taskId, QaId)
+		_, err = relationStmt.Exec(taskId, QaId, answer.QaNumber)
 		if err != nil {
 			return false, err
 		}
-		if !CheckFieldValueExist("task_qa_relations", "qa_id", QaId) {
+		if !checkFieldValueExist(tx, "task_qa_relations", "qa_id", QaId) {
 			return false, fmt.Errorf("添加题目关联失败")
 		}
-		logger.Infof("添加题目与任务关联成功")
+
+		// 关联知识点
+		if err = setQuestionKnowledgePoints(tx, QaId, answer.KpIds); err != nil {
+			return false, fmt.Errorf("第%d题 %w", answer.QaNumber, err)
+		}
+	}
+
+	// 关联题库中的题目
+	for i, qaId := range bankQaIds {
+		var inBank bool
+		err = tx.QueryRow(`SELECT in_bank FROM task_data WHERE qa_id = ?`, qaId).Scan(&inBank)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !inBank) {
+			return false, fmt.Errorf("%w: %s", ErrQuestionNotFound, qaId)
+		}
+		if err != nil {
+			return false, err
+		}
+		if _, err = relationStmt.Exec(taskId, qaId, maxNumber+i+1); err != nil {
+			return false, err
+		}
+	}
+
+	// 布置到班级
+	if err = setTaskClasses(tx, taskId, taskSetting.ClassIds); err != nil {
+		return false, err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	logger.Info("添加任务成功")
 	return true, nil
 }
 
@@ -267,6 +829,11 @@ type TaskInfo struct {
 	TaskDescription string
 	PublishTime     string
 	Deadline        string
+	AllowLate       bool
+	AnswerRelease   string
+	MaxAttempts     int
+	AttemptPolicy   string
+	TimeLimit       int
 }
 
 // GetInfo 获取任务信息
@@ -277,7 +844,7 @@ func GetInfo(taskId string) (taskInfo *TaskInfo, err error) {
 	taskInfo = &TaskInfo{}
 
 	// 获取tasks中的数据
-	stmt, err := db.Prepare(`SELECT task_title, task_description, publish_time, Deadline FROM tasks WHERE task_id = ?`)
+	stmt, err := db.Prepare(`SELECT task_title, task_description, publish_time, Deadline, allow_late, answer_release, max_attempts, attempt_policy, time_limit FROM tasks WHERE task_id = ?`)
 	if err != nil {
 		return nil, err
 	}
@@ -300,10 +867,11 @@ func GetInfo(taskId string) (taskInfo *TaskInfo, err error) {
 	}(rows)
 
 	if !rows.Next() {
-		return nil, errors.New("找不到任务")
+		return nil, ErrTaskNotFound
 	}
 
-	err = rows.Scan(&taskInfo.TaskTitle, &taskInfo.TaskDescription, &taskInfo.PublishTime, &taskInfo.Deadline)
+	err = rows.Scan(&taskInfo.TaskTitle, &taskInfo.TaskDescription, &taskInfo.PublishTime, &taskInfo.Deadline, &taskInfo.AllowLate, &taskInfo.AnswerRelease,
+		&taskInfo.MaxAttempts, &taskInfo.AttemptPolicy, &taskInfo.TimeLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -317,7 +885,14 @@ type TeaTaskData struct {
 	QaId     string            `json:"qa_id"`
 	QaTitle  string            `json:"q_title"`
 	QaNumber int               `json:"qa_number"`
+	QaType   string            `json:"q_type"`
 	QaChoice map[string]string `json:"q_choice"` // 存储问题的选项
+	// Draft 学生保存的草稿答案
+	Draft string `json:"draft,omitempty"`
+	// TimeLimit 每次作答的时限(秒) 0表示不限时
+	TimeLimit int `json:"time_limit"`
+	// RemainingTime 本次作答的剩余时间(秒) 不限时时为null
+	RemainingTime *int `json:"remaining_time"`
 }
 
 // GetTaskData 获取学生任务数据
@@ -325,7 +900,7 @@ func GetTaskData(taskId string) (taskData *[]TeaTaskData, err error) {
 	logger, _ := NewLogger()
 	taskData = &[]TeaTaskData{}
 
-	stmt, err := db.Prepare(`SELECT qa_id, q_title, q_choice, qa_number FROM task_data WHERE qa_id IN (SELECT qa_id FROM task_qa_relations WHERE task_id = ?)`)
+	stmt, err := db.Prepare(`SELECT td.qa_id, td.q_title, td.q_choice, tqr.qa_number, td.q_type, td.q_config FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ? ORDER BY tqr.qa_number`)
 	if err != nil {
 		return nil, err
 	}
@@ -350,31 +925,41 @@ func GetTaskData(taskId string) (taskData *[]TeaTaskData, err error) {
 	for rows.Next() {
 		var qa TeaTaskData
 		var qAnswer string
+		var qConfig string
 
-		err = rows.Scan(&qa.QaId, &qa.QaTitle, &qAnswer, &qa.QaNumber)
+		err = rows.Scan(&qa.QaId, &qa.QaTitle, &qAnswer, &qa.QaNumber, &qa.QaType, &qConfig)
 		if err != nil {
 			return nil, err
 		}
 
-		// 构造默认的QaChoice
-		qa.QaChoice = map[string]string{
-			"A": "这是答题卡，填写答案即可。",
-			"B": "这是答题卡，填写答案即可。",
-			"C": "这是答题卡，填写答案即可。",
-			"D": "这是答题卡，填写答案即可。",
-		}
+		// 按题型构造QaChoice 不返回答案
+		qa.QaChoice = ParseQuestionConfig(qa.QaType, qConfig).Choices(qa.QaType)
 
 		*taskData = append(*taskData, qa)
 		logger.Info("获取任务数据成功")
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 	if len(*taskData) == 0 {
-		return nil, errors.New("获取任务数据失败")
+		// 区分任务不存在与任务中没有题目
+		exists, err := taskExists(db, taskId)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrTaskNotFound
+		}
+		return nil, ErrNoQuestions
 	}
 
 	return taskData, nil
 }
 
+// ErrDuplicateAnswer 同一次提交中同一题目出现多次
+var ErrDuplicateAnswer = errors.New("同一题目重复作答")
+
 // StuTaskData 学生的任务数据
 type StuTaskData struct {
 	QaId      string `json:"qa_id"`
@@ -382,13 +967,63 @@ type StuTaskData struct {
 	SpendTime string `json:"spend_time"`
 }
 
-// PushTaskData 更新任务数据
-func PushTaskData(StudentId string, taskId string, taskData *[]StuTaskData) (success bool, err error) {
+// PushTaskData 提交任务数据 答案、答题时间与评分在同一事务中写入
+// 答案写入最近一次未提交的作答 没有时开始新的作答 已达到最大提交次数时返回ErrNoAttemptsLeft
+// 保存过的草稿一并提交 提交中已有的题目以提交为准
+func PushTaskData(StudentId string, taskId string, taskData *[]StuTaskData, finishedTime string, isLate bool) (*TaskScore, error) {
+	logger, _ := NewLogger()
+
+	// 限时任务已超时的作答以草稿自动提交 本次提交的答案不再接收
+	closed, err := CloseExpiredAttempt(StudentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	if closed {
+		return nil, ErrTimeLimitExceeded
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer rollback(tx)
+
+	if err = checkTimeLimit(tx, StudentId, taskId); err != nil {
+		return nil, err
+	}
+	attempt, err := nextAttempt(tx, StudentId, taskId, "")
+	if err != nil {
+		return nil, err
+	}
+	taskScore, err := submitAttempt(tx, StudentId, taskId, attempt, *taskData, finishedTime, isLate)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("更新任务数据成功")
+	return taskScore, nil
+}
+
+// submitAttempt 合并草稿后写入一次作答的答案和提交时间并自动评分
+func submitAttempt(tx dbExecutor, StudentId string, taskId string, attempt int, taskData []StuTaskData, finishedTime string, isLate bool) (*TaskScore, error) {
 	logger, _ := NewLogger()
+
+	if err := checkSubmittedAnswers(tx, taskId, taskData); err != nil {
+		return nil, err
+	}
+	answers, err := mergeDraft(tx, StudentId, taskId, taskData)
+	if err != nil {
+		return nil, err
+	}
+
 	// 写入student_task_answers数据库
-	stmt, err := db.Prepare(`INSERT INTO student_task_answers (student_id, task_id, qa_id, answer) VALUES (?, ?, ?, ?)`)
+	stmt, err := tx.Prepare(`INSERT INTO student_task_answers (student_id, task_id, qa_id, answer, attempt, spend_time) VALUES (?, ?, ?, ?, ?, ?)`)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer func(stmt *sql.Stmt) {
 		closeErr := stmt.Close()
@@ -396,64 +1031,84 @@ func PushTaskData(StudentId string, taskId string, taskData *[]StuTaskData) (suc
 			logger.Error(closeErr)
 		}
 	}(stmt)
-	for _, data := range *taskData {
-		_, err = stmt.Exec(StudentId, taskId, data.QaId, data.QAnswer)
+	for _, data := range answers {
+		_, err = stmt.Exec(StudentId, taskId, data.QaId, data.QAnswer, attempt, normalizeSpendTime(data.SpendTime))
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 	}
-	logger.Info("更新任务数据成功")
-	return true, nil
 
+	// 写入答题时间
+	if err = pushAnswerTime(tx, StudentId, taskId, attempt, finishedTime, isLate); err != nil {
+		return nil, err
+	}
+
+	// 自动评分
+	return gradeStudentTask(tx, StudentId, taskId)
+}
+
+// checkSubmittedAnswers 检查提交的题目都属于该任务且没有重复
+func checkSubmittedAnswers(ex dbExecutor, taskId string, taskData []StuTaskData) error {
+	seen := make(map[string]bool, len(taskData))
+	for _, data := range taskData {
+		if seen[data.QaId] {
+			return fmt.Errorf("%w: %s", ErrDuplicateAnswer, data.QaId)
+		}
+		seen[data.QaId] = true
+
+		var count int
+		err := ex.QueryRow(`SELECT COUNT(*) FROM task_qa_relations WHERE task_id = ? AND qa_id = ?`, taskId, data.QaId).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: %s", ErrQuestionNotFound, data.QaId)
+		}
+	}
+	return nil
 }
 
 // MarkGetTaskTime 写入获取任务的时间
+// 最近一次作答已提交且还有提交次数时开始新的作答 否则保持当前作答不变
 func MarkGetTaskTime(StudentId string, taskId string) (success bool, err error) {
 	logger, _ := NewLogger()
 
-	// 检查是否已存在对应的记录
-	var count int
-	err = db.QueryRow(`SELECT COUNT(*) FROM task_time WHERE student_id = ? AND task_id = ?`, StudentId, taskId).Scan(&count)
+	// 已超时的作答先自动提交 再按提交次数决定是否开始新的作答
+	if _, err = CloseExpiredAttempt(StudentId, taskId); err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
 		return false, err
 	}
+	defer rollback(tx)
 
-	// 如果记录已存在，则返回成功并忽略插入操作
-	if count > 0 {
-		logger.Info("任务时间已存在，无需更新")
+	attempt, err := nextAttempt(tx, StudentId, taskId, time.Now().Format("2006-01-02 15:04:05.000"))
+	if errors.Is(err, ErrNoAttemptsLeft) {
+		logger.Info("已达到最大提交次数，不再开始新的作答")
 		return true, nil
 	}
-
-	// 准备插入语句
-	timeStmt, err := db.Prepare(`INSERT INTO task_time (student_id, task_id, get_task_time ,push_answer_time) VALUES (?, ?, ?, ?)`)
 	if err != nil {
 		return false, err
 	}
-	defer func() {
-		closeErr := timeStmt.Close()
-		if closeErr != nil {
-			logger.Error(closeErr)
-		}
-	}()
 
-	// 执行插入操作
-	_, err = timeStmt.Exec(StudentId, taskId, time.Now().Format("2006-01-02 15:04:05.000"), "")
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		return false, err
 	}
 
-	logger.Info("更新任务时间成功")
+	logger.Infof("更新任务时间成功 作答次数: %d", attempt)
 	return true, nil
 }
 
-// PushAnswerTime 学生答题时间
-func PushAnswerTime(StudentId string, taskId string, finishedTime string) (success bool, err error) {
+// pushAnswerTime 学生答题时间
+func pushAnswerTime(ex dbExecutor, StudentId string, taskId string, attempt int, finishedTime string, isLate bool) error {
 	logger, _ := NewLogger()
 
 	// 更新学生答题时间
-	timeStmt, err := db.Prepare(`UPDATE task_time SET push_answer_time = ? WHERE student_id = ? AND task_id = ?`)
+	timeStmt, err := ex.Prepare(`UPDATE task_time SET push_answer_time = ?, is_late = ? WHERE student_id = ? AND task_id = ? AND attempt = ?`)
 	if err != nil {
-		return false, err
+		return err
 	}
 	defer func() {
 		closeErr := timeStmt.Close()
@@ -461,20 +1116,29 @@ func PushAnswerTime(StudentId string, taskId string, finishedTime string) (succe
 			logger.Error(closeErr)
 		}
 	}()
-	_, err = timeStmt.Exec(finishedTime, StudentId, taskId)
+	_, err = timeStmt.Exec(finishedTime, isLate, StudentId, taskId, attempt)
 	if err != nil {
-		return false, err
+		return err
 	}
 	logger.Info("更新学生答题时间成功")
-	return true, nil
+	return nil
 }
 
 // TaskData 报告数据结构体
 type TaskData struct {
-	QaID      string `json:"qa_id"`
-	QaNumber  int    `json:"qa_number"`
-	TeaAnswer string `json:"tea_answer"`
-	StuAnswer string `json:"stu_answer"`
+	QaID      string  `json:"qa_id"`
+	QaNumber  int     `json:"qa_number"`
+	QaType    string  `json:"q_type"`
+	TeaAnswer string  `json:"tea_answer"`
+	StuAnswer string  `json:"stu_answer"`
+	IsCorrect bool    `json:"is_correct"`
+	Score     float64 `json:"score"`
+	// Points 本题分值
+	Points float64 `json:"points"`
+	// Comment 教师评语 评分完成后才返回
+	Comment string `json:"comment"`
+	// SpendTime 本题用时(秒) 没有记录时为空
+	SpendTime string `json:"spend_time"`
 }
 
 // StuTaskReport 学生任务报告数据请求结构体
@@ -482,10 +1146,25 @@ type StuTaskReport struct {
 	TaskTitle  string
 	FinishTime string
 	SpendTime  string
-	TaskData   []TaskData
+	IsLate     bool
+	// AnswerReleased 正确答案是否已公布 未公布时TeaAnswer为空
+	AnswerReleased bool
+	// GradingComplete 是否已完成评分 未完成时不返回总分、每题得分与评语
+	GradingComplete bool
+	// Score 按计分方式合并各次提交后的最终得分
+	Score     float64
+	FullScore float64
+	// Attempt 本报告展示的作答 TaskData、FinishTime等均为该次作答的内容
+	Attempt       int
+	MaxAttempts   int
+	AttemptPolicy string
+	// Attempts 全部作答记录
+	Attempts []AttemptRecord
+	TaskData []TaskData
 }
 
-func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
+// GetReportData 获取学生的任务报告 attempt为0时展示计入成绩的作答 尚未提交时展示最近一次作答
+func GetReportData(StudentId string, taskId string, attempt int) (*StuTaskReport, error) {
 	logger, _ := NewLogger()
 	// 获取任务信息的任务标题
 	info, err := GetInfo(taskId)
@@ -495,14 +1174,62 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 
 	// 初始化报告数据，并填充 TaskTitle
 	report := &StuTaskReport{
-		TaskTitle: info.TaskTitle,
+		TaskTitle:     info.TaskTitle,
+		MaxAttempts:   info.MaxAttempts,
+		AttemptPolicy: info.AttemptPolicy,
+	}
+
+	// 任务中没有题目时没有报告
+	var questionCount int
+	err = db.QueryRow(`SELECT COUNT(*) FROM task_qa_relations WHERE task_id = ?`, taskId).Scan(&questionCount)
+	if err != nil {
+		return nil, err
+	}
+	if questionCount == 0 {
+		return nil, ErrNoQuestions
+	}
+
+	// 作答记录
+	report.Attempts, err = ListAttempts(StudentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, record := range report.Attempts {
+		if (attempt == 0 && record.Counted) || record.Attempt == attempt {
+			report.Attempt = record.Attempt
+			found = true
+		}
+	}
+	if !found {
+		if attempt != 0 {
+			return nil, ErrAttemptNotFound
+		}
+		if len(report.Attempts) > 0 {
+			report.Attempt = report.Attempts[len(report.Attempts)-1].Attempt
+		} else {
+			// 没有答题时间记录的旧数据只有第1次作答
+			report.Attempt = 1
+		}
+	}
+
+	// 所选的作答尚未提交时没有报告 兼容没有答题时间记录的旧数据
+	var submitted int
+	err = db.QueryRow(`SELECT (SELECT COUNT(*) FROM task_time WHERE student_id = ? AND task_id = ? AND attempt = ? AND push_answer_time != '')
+		+ (SELECT COUNT(*) FROM student_task_answers WHERE student_id = ? AND task_id = ? AND attempt = ?)`,
+		StudentId, taskId, report.Attempt, StudentId, taskId, report.Attempt).Scan(&submitted)
+	if err != nil {
+		return nil, err
+	}
+	if submitted == 0 {
+		return nil, ErrSubmissionNotFound
 	}
 
 	spendTime := ""
 	finishTime := ""
 
 	// 从task_time获取学生答题时间
-	reportStmt, err := db.Prepare(`SELECT push_answer_time, get_task_time FROM task_time WHERE student_id = ? AND task_id = ?`)
+	reportStmt, err := db.Prepare(`SELECT push_answer_time, get_task_time, is_late FROM task_time WHERE student_id = ? AND task_id = ? AND attempt = ?`)
 	defer func() {
 		closeErr := reportStmt.Close()
 		if closeErr != nil {
@@ -515,7 +1242,7 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 			logger.Error(closeErr)
 		}
 	}()
-	rows, err := reportStmt.Query(StudentId, taskId)
+	rows, err := reportStmt.Query(StudentId, taskId, report.Attempt)
 	if err != nil {
 		return nil, err
 	}
@@ -528,7 +1255,7 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 	for rows.Next() {
 		var pushAnswerTime string
 		var getTaskTime string
-		err = rows.Scan(&pushAnswerTime, &getTaskTime)
+		err = rows.Scan(&pushAnswerTime, &getTaskTime, &report.IsLate)
 		if err != nil {
 			return nil, err
 		}
@@ -543,7 +1270,7 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 	}
 
 	// 根据task_id和qa_id关联，从tasks、task_qa_relations和task_data表中获取正确答案q_choice
-	qaRelationStmt, err := db.Prepare(`SELECT td.qa_id, td.q_choice, td.qa_number FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ?`)
+	qaRelationStmt, err := db.Prepare(`SELECT td.qa_id, td.q_choice, tqr.qa_number, td.q_type, td.points FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ?`)
 	if err != nil {
 		return nil, err
 	}
@@ -566,7 +1293,7 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 	}(rowsQARelation)
 
 	// 根据student_id和task_id从student_task_answers取出学生答题内容
-	stuAnswerStmt, err := db.Prepare(`SELECT qa_id, answer FROM student_task_answers WHERE student_id = ? AND task_id = ?`)
+	stuAnswerStmt, err := db.Prepare(`SELECT qa_id, answer, is_correct, score, comment, spend_time FROM student_task_answers WHERE student_id = ? AND task_id = ? AND attempt = ?`)
 	if err != nil {
 		return nil, err
 	}
@@ -577,7 +1304,7 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 		}
 	}(stuAnswerStmt)
 
-	rowsStuAnswer, err := stuAnswerStmt.Query(StudentId, taskId)
+	rowsStuAnswer, err := stuAnswerStmt.Query(StudentId, taskId, report.Attempt)
 	if err != nil {
 		return nil, err
 	}
@@ -588,25 +1315,31 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 		}
 	}(rowsStuAnswer)
 
-	var taskDataList []TaskData
+	taskDataList := []TaskData{}
 	qaMap := make(map[string]QAChoice)
 
 	for rowsQARelation.Next() {
 		var qaID string
 		var qChoice string
 		var qNumber int
-		err = rowsQARelation.Scan(&qaID, &qChoice, &qNumber)
+		var qType string
+		var points float64
+		err = rowsQARelation.Scan(&qaID, &qChoice, &qNumber, &qType, &points)
 		if err != nil {
 			return nil, err
 		}
-		qaChoice := QAChoice{qChoice, qNumber}
+		qaChoice := QAChoice{qChoice, qNumber, qType, points}
 		qaMap[qaID] = qaChoice
 	}
 
 	for rowsStuAnswer.Next() {
 		var qaID string
 		var stuAnswer string
-		err = rowsStuAnswer.Scan(&qaID, &stuAnswer)
+		var isCorrect bool
+		var score float64
+		var comment string
+		var spendTime string
+		err = rowsStuAnswer.Scan(&qaID, &stuAnswer, &isCorrect, &score, &comment, &spendTime)
 		if err != nil {
 			return nil, err
 		}
@@ -617,12 +1350,53 @@ func GetReportData(StudentId string, taskId string) (*StuTaskReport, error) {
 		taskData := TaskData{
 			QaID:      qaID,
 			QaNumber:  teaAnswer.QNumber,
+			QaType:    teaAnswer.QType,
 			TeaAnswer: teaAnswer.Answer,
 			StuAnswer: stuAnswer,
+			IsCorrect: isCorrect,
+			Score:     score,
+			Points:    teaAnswer.Points,
+			Comment:   comment,
+			SpendTime: spendTime,
 		}
 		taskDataList = append(taskDataList, taskData)
 	}
 
+	// 获取学生任务总分
+	taskScore, err := GetTaskScore(StudentId, taskId)
+	if err != nil {
+		return nil, err
+	}
+	if taskScore != nil {
+		report.GradingComplete = taskScore.GradingComplete
+		report.FullScore = taskScore.FullScore
+		if taskScore.GradingComplete {
+			report.Score = taskScore.Score
+		}
+	}
+	// 评分未完成时不返回每题得分、对错、评语与各次作答得分 避免在教师评分前泄露部分成绩
+	if !report.GradingComplete {
+		for i := range taskDataList {
+			taskDataList[i].IsCorrect = false
+			taskDataList[i].Score = 0
+			taskDataList[i].Comment = ""
+		}
+		for i := range report.Attempts {
+			report.Attempts[i].Score = 0
+		}
+	}
+
+	// 按任务的答案公布策略决定是否返回正确答案
+	report.AnswerReleased, err = IsAnswerReleased(taskId, StudentId)
+	if err != nil {
+		return nil, err
+	}
+	if !report.AnswerReleased {
+		for i := range taskDataList {
+			taskDataList[i].TeaAnswer = ""
+		}
+	}
+
 	report.SpendTime = spendTime
 	report.FinishTime = finishTime
 	report.TaskData = taskDataList
@@ -643,10 +1417,22 @@ func GetSpendTimeInSeconds(getTaskTime, pushAnswerTime string) string {
 	return strconv.Itoa(secondsDiff)
 }
 
+// normalizeSpendTime 校验客户端上报的逐题用时(秒) 无法解析或为负数时不记录
+func normalizeSpendTime(spendTime string) string {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(spendTime), 64)
+	if err != nil || seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return ""
+	}
+	return strconv.FormatFloat(seconds, 'f', -1, 64)
+}
+
 // QAChoice 单个题目及其答案选项结构体
 type QAChoice struct {
 	Answer  string `json:"answer"`
 	QNumber int    `json:"question_number"`
+	QType   string `json:"q_type"`
+	// Points 本题分值
+	Points float64 `json:"points"`
 }
 
 // AnswerItem 定义AnswerItem结构体
@@ -656,17 +1442,45 @@ type AnswerItem struct {
 	Answer   string `json:"answer"`
 }
 
+// CorrectAnswerItem 正确答案及本题分值
+type CorrectAnswerItem struct {
+	AnswerItem
+	Points float64 `json:"points"`
+}
+
+// GradedAnswerItem 带评分结果的学生答案
+type GradedAnswerItem struct {
+	AnswerItem
+	IsCorrect bool    `json:"is_correct"`
+	Score     float64 `json:"score"`
+	// Points 本题分值
+	Points float64 `json:"points"`
+	// SpendTime 本题用时(秒) 没有记录时为空
+	SpendTime string `json:"spend_time"`
+}
+
 // StudentAnswer 定义StudentAnswer结构体
 type StudentAnswer struct {
-	UserID  string       `json:"user_id"`
-	Answers []AnswerItem `json:"answers"`
+	UserID    string  `json:"user_id"`
+	IsLate    bool    `json:"is_late"`
+	Score     float64 `json:"score"`
+	FullScore float64 `json:"full_score"`
+	// GradingComplete 是否已完成人工评分
+	GradingComplete bool `json:"grading_complete"`
+	// Attempt 计入成绩的作答 Answers为该次作答的答案
+	Attempt  int                `json:"attempt"`
+	Attempts []AttemptRecord    `json:"attempts"`
+	Answers  []GradedAnswerItem `json:"answers"`
 }
 
 // StatusTaskData 定义TaskData结构体
 type StatusTaskData struct {
-	TaskTitle     string          `json:"task_title"`
-	CorrectAnswer []AnswerItem    `json:"correctAnswer"`
-	StudentAnswer []StudentAnswer `json:"studentAnswer"`
+	TaskTitle     string              `json:"task_title"`
+	FullScore     float64             `json:"full_score"`
+	CorrectAnswer []CorrectAnswerItem `json:"correctAnswer"`
+	StudentAnswer []StudentAnswer     `json:"studentAnswer"`
+	// Students 所有布置或参与的学生及其作答状态
+	Students []StudentStatus `json:"students"`
 }
 
 // GetStatusReportData 获取学生任务状态报告数据
@@ -684,7 +1498,7 @@ func GetStatusReportData(taskId string) (*StatusTaskData, error) {
 	}
 
 	// 获取正确答案
-	qaRelationStmt, err := db.Prepare(`SELECT td.qa_id, td.q_choice, td.qa_number FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ?`)
+	qaRelationStmt, err := db.Prepare(`SELECT td.qa_id, td.q_choice, tqr.qa_number, td.points FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ? ORDER BY tqr.qa_number`)
 	defer func(qaRelationStmt *sql.Stmt) {
 		err := qaRelationStmt.Close()
 		if err != nil {
@@ -714,15 +1528,20 @@ func GetStatusReportData(taskId string) (*StatusTaskData, error) {
 		var qaID string
 		var qChoice string
 		var qNumber int
-		err = rowsQARelation.Scan(&qaID, &qChoice, &qNumber)
+		var points float64
+		err = rowsQARelation.Scan(&qaID, &qChoice, &qNumber, &points)
 		if err != nil {
 			return nil, err
 		}
-		data.CorrectAnswer = append(data.CorrectAnswer, AnswerItem{qaID, qNumber, qChoice})
+		data.CorrectAnswer = append(data.CorrectAnswer, CorrectAnswerItem{AnswerItem{qaID, qNumber, qChoice}, points})
+		data.FullScore += points
 	}
 
 	// 在student_task_answers通过task_id获取所有学生针对此任务的答题内容，并整合到StatusTaskData结构体中
-	stuAnswerStmt, err := db.Prepare(`SELECT student_id, qa_id, answer FROM student_task_answers WHERE task_id = ?`)
+	// 每个学生只取计入成绩的作答
+	stuAnswerStmt, err := db.Prepare(`SELECT sta.student_id, sta.qa_id, sta.answer, sta.is_correct, sta.score, sta.spend_time FROM student_task_answers sta
+		INNER JOIN student_task_scores sts ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+		WHERE sta.task_id = ?`)
 	if err != nil {
 		return nil, fmt.Errorf("准备查询学生答题记录SQL语句时出错: %v", err)
 	}
@@ -743,35 +1562,46 @@ func GetStatusReportData(taskId string) (*StatusTaskData, error) {
 		}
 	}(rowsStuAnswer)
 
+	// 通过qa_id在task_data获取题目序号，这里假设每道题目对应的结果唯一
+	qaNumberStmt, err := db.Prepare(`SELECT tqr.qa_number, td.points FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ? AND td.qa_id = ?`)
+	if err != nil {
+		return nil, fmt.Errorf("准备查询题目序号SQL语句时出错: %v", err)
+	}
+	defer func() {
+		closeErr := qaNumberStmt.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}()
+
 	var studentAnswers []StudentAnswer
 	for rowsStuAnswer.Next() {
 		var studentID string
 		var qaID string
 		var stuAnswer string
-		err = rowsStuAnswer.Scan(&studentID, &qaID, &stuAnswer)
+		var isCorrect bool
+		var score float64
+		var spendTime string
+		err = rowsStuAnswer.Scan(&studentID, &qaID, &stuAnswer, &isCorrect, &score, &spendTime)
 		if err != nil {
 			return nil, fmt.Errorf("扫描学生答题记录结果时出错: %v", err)
 		}
 
-		// 通过qa_id在task_data获取题目序号，这里假设每道题目对应的结果唯一
-		qaRelationStmt, err := db.Prepare(`SELECT qa_number FROM task_data WHERE qa_id = ?`)
-		if err != nil {
-			return nil, fmt.Errorf("准备查询题目序号SQL语句时出错: %v", err)
-		}
-		defer func() {
-			closeErr := qaRelationStmt.Close()
-			if closeErr != nil {
-				logger.Error(closeErr)
-			}
-		}()
 		var qNumber int
-		err = qaRelationStmt.QueryRow(qaID).Scan(&qNumber)
+		var points float64
+		err = qaNumberStmt.QueryRow(taskId, qaID).Scan(&qNumber, &points)
+		if errors.Is(err, sql.ErrNoRows) {
+			// 不属于该任务的答案不影响其他学生的报告
+			logger.Warnf("跳过不属于任务的答案 task_id: %s qa_id: %s student_id: %s", taskId, qaID, studentID)
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("查询题目序号时出错: %v", err)
 		}
 
 		// 构建StudentAnswer结构体并添加到列表中
-		studentAnswer := StudentAnswer{UserID: studentID, Answers: []AnswerItem{{QaID: qaID, QaNumber: qNumber, Answer: stuAnswer}}}
+		answerItem := GradedAnswerItem{AnswerItem{qaID, qNumber, stuAnswer}, isCorrect, score, points, spendTime}
+		studentAnswer := StudentAnswer{UserID: studentID, Answers: []GradedAnswerItem{answerItem}}
 		studentAnswers = append(studentAnswers, studentAnswer)
 
 		// 更新或创建StatusTaskData中的StudentAnswer字段（这里假设每个学生的答案会分组在一起）
@@ -779,7 +1609,7 @@ func GetStatusReportData(taskId string) (*StatusTaskData, error) {
 		for i, sa := range data.StudentAnswer {
 			if sa.UserID == studentID {
 				found = true
-				data.StudentAnswer[i].Answers = append(data.StudentAnswer[i].Answers, AnswerItem{qaID, qNumber, stuAnswer})
+				data.StudentAnswer[i].Answers = append(data.StudentAnswer[i].Answers, answerItem)
 				break
 			}
 		}
@@ -788,6 +1618,36 @@ func GetStatusReportData(taskId string) (*StatusTaskData, error) {
 		}
 	}
 
+	// 填充每个学生的作答记录、迟交标记与任务总分
+	for i, sa := range data.StudentAnswer {
+		data.StudentAnswer[i].Attempts, err = ListAttempts(sa.UserID, taskId)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range data.StudentAnswer[i].Attempts {
+			if record.Counted {
+				data.StudentAnswer[i].IsLate = record.IsLate
+			}
+		}
+
+		taskScore, err := GetTaskScore(sa.UserID, taskId)
+		if err != nil {
+			return nil, err
+		}
+		if taskScore != nil {
+			data.StudentAnswer[i].Score = taskScore.Score
+			data.StudentAnswer[i].FullScore = taskScore.FullScore
+			data.StudentAnswer[i].GradingComplete = taskScore.GradingComplete
+			data.StudentAnswer[i].Attempt = taskScore.Attempt
+		}
+	}
+
+	// 获取所有学生的作答状态
+	data.Students, err = GetTaskStudentStatus(taskId)
+	if err != nil {
+		return nil, err
+	}
+
 	// 确保在处理完所有学生答案后返回数据
 	return data, nil
 
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"ZhiShanYunXue/setting"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// useTestDB 将包内数据库替换为临时目录中的新数据库 测试结束后恢复
+func useTestDB(t *testing.T) {
+	t.Helper()
+	testDB, err := sql.Open(setting.DbDriverName, fmt.Sprintf("%s?_busy_timeout=%d", filepath.Join(t.TempDir(), "test.sqlite"), setting.DbBusyTimeout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDB := db
+	db = testDB
+	t.Cleanup(func() {
+		db = oldDB
+		testDB.Close()
+	})
+}
+
+// initTestDB 使用临时数据库并按当前表结构初始化
+func initTestDB(t *testing.T) {
+	t.Helper()
+	useTestDB(t)
+	t.Setenv(setting.AdminPasswordEnv, "test-password")
+	InitSqlite()
+}
+
+func TestInitSqliteMigratesPreAttemptDatabase(t *testing.T) {
+	useTestDB(t)
+	t.Setenv(setting.AdminPasswordEnv, "test-password")
+
+	// 多次作答之前的表结构 每个学生每个任务只有一条作答
+	statements := []string{
+		`create table tasks (task_id TEXT not null, task_title TEXT not null, task_description TEXT not null, publish_time TEXT not null, Deadline TEXT not null)`,
+		`create table task_data (qa_id TEXT not null, q_title TEXT, qa_number INT not null, q_choice TEXT not null)`,
+		`create table task_qa_relations (task_id TEXT not null, qa_id TEXT not null, primary key (task_id, qa_id))`,
+		`create table student_task_answers (student_id TEXT not null, task_id TEXT not null, qa_id TEXT not null, answer TEXT not null,
+			is_correct INT not null default 0, score REAL not null default 0, UNIQUE (student_id, task_id, qa_id))`,
+		`create table task_time (student_id text not null, task_id text not null, get_task_time text not null, push_answer_time text not null,
+			unique (student_id, task_id))`,
+		`INSERT INTO tasks VALUES ('t1', 'title', 'desc', '2024-01-01 08:00:00.000', '2030-01-01 23:59:59')`,
+		`INSERT INTO task_data VALUES ('q1', 'q1', 1, 'A'), ('q2', 'q2', 2, 'B')`,
+		`INSERT INTO task_qa_relations VALUES ('t1', 'q1'), ('t1', 'q2')`,
+		`INSERT INTO student_task_answers VALUES ('s1', 't1', 'q1', 'A', 1, 1), ('s1', 't1', 'q2', 'C', 0, 0), ('s2', 't1', 'q1', 'B', 0, 0)`,
+		`INSERT INTO task_time VALUES ('s1', 't1', '2024-01-02 08:00:00.000', '2024-01-02 08:10:00.000'),
+			('s2', 't1', '2024-01-02 09:00:00.000', '')`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("准备旧数据库失败: %v\n%s", err, statement)
+		}
+	}
+
+	InitSqlite()
+
+	// 迁移全部完成 最后一步补充的字段存在
+	columns, err := tableColumns(db, "tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsString(columns, "time_limit") {
+		t.Fatalf("迁移未完成 tasks字段: %v", columns)
+	}
+
+	// 原有作答保留为第1次作答
+	var answers, firstAttempt int
+	err = db.QueryRow(`SELECT COUNT(*), SUM(attempt = 1) FROM student_task_answers`).Scan(&answers, &firstAttempt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answers != 3 || firstAttempt != 3 {
+		t.Errorf("student_task_answers 共%d条 其中第1次作答%d条 want 3/3", answers, firstAttempt)
+	}
+	var answer string
+	var score float64
+	err = db.QueryRow(`SELECT answer, score FROM student_task_answers WHERE student_id = 's1' AND qa_id = 'q1'`).Scan(&answer, &score)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "A" || score != 1 {
+		t.Errorf("s1 q1 = (%q, %v), want (A, 1)", answer, score)
+	}
+
+	// 已提交作答的得分由答题记录回填 未提交的保持0分
+	var attempt int
+	var pushTime string
+	err = db.QueryRow(`SELECT attempt, push_answer_time, score FROM task_time WHERE student_id = 's1' AND task_id = 't1'`).Scan(&attempt, &pushTime, &score)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt != 1 || pushTime != "2024-01-02 08:10:00.000" || score != 1 {
+		t.Errorf("s1 task_time = (%d, %q, %v), want (1, 2024-01-02 08:10:00.000, 1)", attempt, pushTime, score)
+	}
+	err = db.QueryRow(`SELECT score FROM task_time WHERE student_id = 's2' AND task_id = 't1'`).Scan(&score)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 0 {
+		t.Errorf("s2 task_time score = %v, want 0", score)
+	}
+
+	// 重建后的唯一约束包含作答序号 可以写入第2次作答
+	if _, err = db.Exec(`INSERT INTO student_task_answers (student_id, task_id, qa_id, answer, attempt) VALUES ('s1', 't1', 'q1', 'B', 2)`); err != nil {
+		t.Errorf("写入第2次作答失败: %v", err)
+	}
+	if _, err = db.Exec(`INSERT INTO task_time (student_id, task_id, get_task_time, push_answer_time, attempt) VALUES ('s1', 't1', '', '', 2)`); err != nil {
+		t.Errorf("写入第2次答题时间失败: %v", err)
+	}
+	if _, err = db.Exec(`INSERT INTO student_task_answers (student_id, task_id, qa_id, answer, attempt) VALUES ('s1', 't1', 'q1', 'C', 2)`); err == nil {
+		t.Error("同一次作答重复写入同一题 want 唯一约束错误")
+	}
+
+	// 再次初始化不会重复迁移
+	InitSqlite()
+	if err = db.QueryRow(`SELECT COUNT(*) FROM student_task_answers`).Scan(&answers); err != nil {
+		t.Fatal(err)
+	}
+	if answers != 4 {
+		t.Errorf("再次初始化后 student_task_answers 共%d条, want 4", answers)
+	}
+}
+
+func TestAddTaskRejectsInvalidQaNumbers(t *testing.T) {
+	initTestDB(t)
+
+	tests := []struct {
+		name    string
+		numbers []int
+	}{
+		{"重复题号", []int{1, 2, 1}},
+		{"题号为负数", []int{1, -2}},
+	}
+	for _, tt := range tests {
+		var answers []QAAnswer
+		for _, number := range tt.numbers {
+			answers = append(answers, QAAnswer{QaTitle: "q", QaNumber: number, QaAnswer: "A"})
+		}
+		_, err := AddTask("t1", "teacher", "t1", "d", "2030-01-01 23:59:59", TaskSetting{MaxAttempts: 1}, answers, nil)
+		if !errors.Is(err, ErrInvalidQuestion) {
+			t.Errorf("%s: error = %v, want ErrInvalidQuestion", tt.name, err)
+		}
+	}
+	if n := countRows(t, `SELECT COUNT(*) FROM tasks`); n != 0 {
+		t.Errorf("校验失败后仍写入了 %d 个任务", n)
+	}
+}
+
+// containsString 判断切片中是否包含该字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// addTestTask 添加一个有两道单选题的任务 返回题目id
+func addTestTask(t *testing.T, taskId string) []string {
+	t.Helper()
+	answers := []QAAnswer{
+		{QaTitle: "q1", QaNumber: 1, QaAnswer: "A"},
+		{QaTitle: "q2", QaNumber: 2, QaAnswer: "B"},
+	}
+	if _, err := AddTask(taskId, "teacher", taskId, "d", "2030-01-01 23:59:59", TaskSetting{MaxAttempts: 1}, answers, nil); err != nil {
+		t.Fatal(err)
+	}
+	taskData, err := GetTaskData(taskId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var qaIds []string
+	for _, data := range *taskData {
+		qaIds = append(qaIds, data.QaId)
+	}
+	return qaIds
+}
+
+func TestPushTaskDataRejectsInvalidAnswers(t *testing.T) {
+	initTestDB(t)
+	qaIds := addTestTask(t, "t1")
+	otherQaIds := addTestTask(t, "t2")
+	now := time.Now().Format("2006-01-02 15:04:05.000")
+
+	tests := []struct {
+		name     string
+		taskData []StuTaskData
+		want     error
+	}{
+		{"重复题目", []StuTaskData{{QaId: qaIds[0], QAnswer: "A"}, {QaId: qaIds[0], QAnswer: "B"}}, ErrDuplicateAnswer},
+		{"其他任务的题目", []StuTaskData{{QaId: qaIds[0], QAnswer: "A"}, {QaId: otherQaIds[0], QAnswer: "A"}}, ErrQuestionNotFound},
+		{"不存在的题目", []StuTaskData{{QaId: "nope", QAnswer: "A"}}, ErrQuestionNotFound},
+	}
+	for _, tt := range tests {
+		_, err := PushTaskData("s1", "t1", &tt.taskData, now, false)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if n := countRows(t, `SELECT COUNT(*) FROM student_task_answers`); n != 0 {
+		t.Errorf("被拒绝的提交写入了 %d 条答案", n)
+	}
+
+	valid := []StuTaskData{{QaId: qaIds[0], QAnswer: "A"}, {QaId: qaIds[1], QAnswer: "C"}}
+	taskScore, err := PushTaskData("s1", "t1", &valid, now, false)
+	if err != nil {
+		t.Fatalf("有效提交 error: %v", err)
+	}
+	if taskScore.Score != 1 {
+		t.Errorf("Score = %v, want 1", taskScore.Score)
+	}
+}
+
+func TestGetStatusReportDataSkipsForeignAnswers(t *testing.T) {
+	initTestDB(t)
+	qaIds := addTestTask(t, "t1")
+	otherQaIds := addTestTask(t, "t2")
+	now := time.Now().Format("2006-01-02 15:04:05.000")
+
+	for _, studentId := range []string{"s1", "s2"} {
+		taskData := []StuTaskData{{QaId: qaIds[0], QAnswer: "A"}, {QaId: qaIds[1], QAnswer: "B"}}
+		if _, err := PushTaskData(studentId, "t1", &taskData, now, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 旧版本可能写入的其他任务的答案
+	if _, err := db.Exec(`INSERT INTO student_task_answers (student_id, task_id, qa_id, answer, attempt) VALUES ('s1', 't1', ?, 'A', 1)`, otherQaIds[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := GetStatusReportData("t1")
+	if err != nil {
+		t.Fatalf("GetStatusReportData error: %v", err)
+	}
+	if len(data.StudentAnswer) != 2 {
+		t.Fatalf("len(StudentAnswer) = %d, want 2", len(data.StudentAnswer))
+	}
+	for _, sa := range data.StudentAnswer {
+		if len(sa.Answers) != 2 {
+			t.Errorf("%s answers = %+v, want 2", sa.UserID, sa.Answers)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package util
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+)
+
+// DefaultHistogramBins 成绩分布直方图的默认分段数
+const DefaultHistogramBins = 10
+
+// HistogramBin 成绩分布直方图的一个分段 除最后一段外不含上界
+type HistogramBin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// TaskSummaryStats 任务成绩汇总统计
+type TaskSummaryStats struct {
+	TaskTitle string  `json:"task_title"`
+	ClassId   string  `json:"class_id"`
+	FullScore float64 `json:"full_score"`
+	// Assigned 应提交人数 即布置班级成员与已获取任务学生的并集
+	Assigned  int `json:"assigned"`
+	Fetched   int `json:"fetched"`
+	Submitted int `json:"submitted"`
+	OnTime    int `json:"on_time"`
+	// SubmissionRate 提交人数占应提交人数的比例
+	SubmissionRate float64 `json:"submission_rate"`
+	// OnTimeRate 按时提交人数占提交人数的比例
+	OnTimeRate float64        `json:"on_time_rate"`
+	Mean       float64        `json:"mean"`
+	Median     float64        `json:"median"`
+	StdDev     float64        `json:"std_dev"`
+	Min        float64        `json:"min"`
+	Max        float64        `json:"max"`
+	Histogram  []HistogramBin `json:"histogram"`
+}
+
+// GetTaskSummary 计算任务的成绩分布与提交情况 classId不为空时只统计该班级的学生
+func GetTaskSummary(taskId string, classId string, bins int) (*TaskSummaryStats, error) {
+	logger, _ := NewLogger()
+
+	info, err := GetInfo(taskId)
+	if err != nil {
+		return nil, err
+	}
+	if bins <= 0 {
+		bins = DefaultHistogramBins
+	}
+	summary := &TaskSummaryStats{
+		TaskTitle: info.TaskTitle,
+		ClassId:   classId,
+	}
+
+	// 统计范围内的学生
+	statuses, err := GetTaskStudentStatus(taskId)
+	if err != nil {
+		return nil, err
+	}
+	var inClass map[string]bool
+	if classId != "" {
+		members, err := GetClassMembers(classId)
+		if err != nil {
+			return nil, err
+		}
+		inClass = make(map[string]bool)
+		for _, member := range members {
+			inClass[member.StudentId] = true
+		}
+	}
+
+	// 满分为所有题目分值之和
+	err = db.QueryRow(`SELECT COALESCE(SUM(td.points), 0) FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ?`, taskId).
+		Scan(&summary.FullScore)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个学生按计分方式合并后的得分 以及计入成绩的作答是否迟交
+	rows, err := db.Query(`SELECT sts.student_id, COALESCE(tt.is_late, 0), sts.score
+		FROM student_task_scores sts
+		LEFT JOIN task_time tt ON tt.student_id = sts.student_id AND tt.task_id = sts.task_id AND tt.attempt = sts.attempt
+		WHERE sts.task_id = ?`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	var scores []float64
+	for rows.Next() {
+		var studentId string
+		var isLate bool
+		var score float64
+		if err = rows.Scan(&studentId, &isLate, &score); err != nil {
+			return nil, err
+		}
+		if inClass != nil && !inClass[studentId] {
+			continue
+		}
+		scores = append(scores, score)
+		if !isLate {
+			summary.OnTime++
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if inClass != nil && !inClass[status.StudentId] {
+			continue
+		}
+		summary.Assigned++
+		if status.State != StateNotFetched {
+			summary.Fetched++
+		}
+	}
+	fillScoreStats(summary, scores, bins)
+
+	logger.Info("任务成绩汇总成功")
+	return summary, nil
+}
+
+// fillScoreStats 根据已统计的应提交与按时人数 计算提交率、成绩统计量与分布直方图
+func fillScoreStats(summary *TaskSummaryStats, scores []float64, bins int) {
+	summary.Submitted = len(scores)
+	if summary.Assigned > 0 {
+		summary.SubmissionRate = float64(summary.Submitted) / float64(summary.Assigned)
+	}
+	if summary.Submitted > 0 {
+		summary.OnTimeRate = float64(summary.OnTime) / float64(summary.Submitted)
+	}
+
+	// 成绩统计量
+	if len(scores) > 0 {
+		sort.Float64s(scores)
+		summary.Min = scores[0]
+		summary.Max = scores[len(scores)-1]
+		summary.Mean = mean(scores)
+		mid := len(scores) / 2
+		if len(scores)%2 == 0 {
+			summary.Median = (scores[mid-1] + scores[mid]) / 2
+		} else {
+			summary.Median = scores[mid]
+		}
+		variance := 0.0
+		for _, score := range scores {
+			variance += (score - summary.Mean) * (score - summary.Mean)
+		}
+		summary.StdDev = math.Sqrt(variance / float64(len(scores)))
+	}
+	summary.Histogram = scoreHistogram(scores, summary.FullScore, bins)
+}
+
+// scoreHistogram 将成绩按0到满分等分为bins段统计人数
+func scoreHistogram(scores []float64, fullScore float64, bins int) []HistogramBin {
+	histogram := make([]HistogramBin, bins)
+	width := fullScore / float64(bins)
+	for i := range histogram {
+		histogram[i].Lower = fullScore * float64(i) / float64(bins)
+		histogram[i].Upper = fullScore * float64(i+1) / float64(bins)
+	}
+	if width <= 0 {
+		return histogram
+	}
+	for _, score := range scores {
+		i := int(score / width)
+		if i >= bins {
+			i = bins - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		histogram[i].Count++
+	}
+	return histogram
+}
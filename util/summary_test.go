@@ -0,0 +1,101 @@
+package util
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFillScoreStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores []float64
+		median float64
+		mean   float64
+		stdDev float64
+		min    float64
+		max    float64
+	}{
+		{"奇数人数", []float64{9, 1, 5}, 5, 5, math.Sqrt(32.0 / 3), 1, 9},
+		{"偶数人数", []float64{4, 2, 8, 6}, 5, 5, math.Sqrt(5), 2, 8},
+		{"单人", []float64{7}, 7, 7, 0, 7, 7},
+		{"全部相同", []float64{3, 3, 3, 3}, 3, 3, 0, 3, 3},
+	}
+	for _, tt := range tests {
+		summary := &TaskSummaryStats{FullScore: 10, Assigned: len(tt.scores), OnTime: len(tt.scores)}
+		fillScoreStats(summary, tt.scores, DefaultHistogramBins)
+		if summary.Submitted != len(tt.scores) {
+			t.Errorf("%s: Submitted = %d, want %d", tt.name, summary.Submitted, len(tt.scores))
+		}
+		if summary.Median != tt.median || summary.Mean != tt.mean || summary.Min != tt.min || summary.Max != tt.max {
+			t.Errorf("%s: median/mean/min/max = %v/%v/%v/%v, want %v/%v/%v/%v", tt.name,
+				summary.Median, summary.Mean, summary.Min, summary.Max, tt.median, tt.mean, tt.min, tt.max)
+		}
+		if math.Abs(summary.StdDev-tt.stdDev) > 1e-9 {
+			t.Errorf("%s: StdDev = %v, want %v", tt.name, summary.StdDev, tt.stdDev)
+		}
+	}
+}
+
+func TestFillScoreStatsRates(t *testing.T) {
+	// 没有提交时比例与统计量均为0 不出现除零
+	summary := &TaskSummaryStats{FullScore: 10, Assigned: 5}
+	fillScoreStats(summary, nil, DefaultHistogramBins)
+	if summary.Submitted != 0 || summary.SubmissionRate != 0 || summary.OnTimeRate != 0 {
+		t.Errorf("无提交: submitted/rate/onTimeRate = %d/%v/%v, want 0/0/0", summary.Submitted, summary.SubmissionRate, summary.OnTimeRate)
+	}
+	if summary.Mean != 0 || summary.Median != 0 || summary.StdDev != 0 {
+		t.Errorf("无提交: mean/median/stdDev = %v/%v/%v, want 0/0/0", summary.Mean, summary.Median, summary.StdDev)
+	}
+	if len(summary.Histogram) != DefaultHistogramBins {
+		t.Errorf("无提交: len(Histogram) = %d, want %d", len(summary.Histogram), DefaultHistogramBins)
+	}
+
+	// 没有应提交学生时提交率为0
+	summary = &TaskSummaryStats{FullScore: 10}
+	fillScoreStats(summary, nil, DefaultHistogramBins)
+	if summary.SubmissionRate != 0 {
+		t.Errorf("无应提交学生: SubmissionRate = %v, want 0", summary.SubmissionRate)
+	}
+
+	summary = &TaskSummaryStats{FullScore: 10, Assigned: 4, OnTime: 1}
+	fillScoreStats(summary, []float64{1, 2}, DefaultHistogramBins)
+	if summary.SubmissionRate != 0.5 || summary.OnTimeRate != 0.5 {
+		t.Errorf("SubmissionRate/OnTimeRate = %v/%v, want 0.5/0.5", summary.SubmissionRate, summary.OnTimeRate)
+	}
+}
+
+func TestScoreHistogram(t *testing.T) {
+	histogram := scoreHistogram([]float64{0, 1.9, 2, 5, 9.99, 10}, 10, 5)
+	wantCounts := []int{2, 1, 1, 0, 2}
+	for i, bin := range histogram {
+		if bin.Lower != float64(i*2) || bin.Upper != float64(i*2+2) {
+			t.Errorf("bin %d = [%v, %v), want [%d, %d)", i, bin.Lower, bin.Upper, i*2, i*2+2)
+		}
+		if bin.Count != wantCounts[i] {
+			t.Errorf("bin %d count = %d, want %d", i, bin.Count, wantCounts[i])
+		}
+	}
+
+	// 满分落在最后一段
+	histogram = scoreHistogram([]float64{100}, 100, DefaultHistogramBins)
+	if histogram[DefaultHistogramBins-1].Count != 1 {
+		t.Errorf("满分未计入最后一段: %+v", histogram)
+	}
+
+	// 超出满分与负分分别计入首末段
+	histogram = scoreHistogram([]float64{-1, 12}, 10, 5)
+	if histogram[0].Count != 1 || histogram[4].Count != 1 {
+		t.Errorf("越界成绩分段错误: %+v", histogram)
+	}
+
+	// 满分为0时只返回空分段
+	histogram = scoreHistogram([]float64{0, 0}, 0, 3)
+	if len(histogram) != 3 {
+		t.Fatalf("len(histogram) = %d, want 3", len(histogram))
+	}
+	for i, bin := range histogram {
+		if bin.Count != 0 {
+			t.Errorf("满分为0时 bin %d count = %d, want 0", i, bin.Count)
+		}
+	}
+}
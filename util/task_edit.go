@@ -0,0 +1,336 @@
+package util
+
+import (
+	"ZhiShanYunXue/setting"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrTaskNotFound 任务不存在
+	ErrTaskNotFound = errors.New("找不到任务")
+	// ErrQuestionNotFound 题目不存在
+	ErrQuestionNotFound = errors.New("找不到题目")
+	// ErrNoQuestions 任务中没有题目
+	ErrNoQuestions = errors.New("任务没有题目")
+)
+
+// TaskUpdate 修改任务的内容 为nil的字段保持不变
+type TaskUpdate struct {
+	TaskTitle       *string
+	TaskDescription *string
+	Deadline        *string
+	AllowLate       *bool
+	AnswerRelease   *string
+	// ClassIds 不为nil时重新设置布置的班级
+	ClassIds *[]string
+	// MaxAttempts 最大提交次数 0表示不限
+	MaxAttempts *int
+	// AttemptPolicy 计分方式变化时按新方式重新计算所有学生的得分
+	AttemptPolicy *string
+	// TimeLimit 每次作答的时限(秒) 0表示不限时
+	TimeLimit *int
+	// Answers 按 QaNumber 匹配已有题目 修改题目标题、答案、分值与部分得分规则
+	// 题库题目或被其他任务引用的题目会先复制为本任务独有的题目 不影响其他任务
+	Answers []QAAnswer
+}
+
+// rollback 回滚事务 已提交的事务直接忽略
+func rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logger, _ := NewLogger()
+		logger.Error("回滚事务失败: ", err)
+	}
+}
+
+// taskExists 检查任务是否存在
+func taskExists(ex dbExecutor, taskId string) (bool, error) {
+	var count int
+	err := ex.QueryRow(`SELECT COUNT(*) FROM tasks WHERE task_id = ?`, taskId).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// UpdateTask 修改任务 答案或计分方式变更时重新评分本任务已提交的学生
+func UpdateTask(taskId string, update TaskUpdate) (regraded int, err error) {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer rollback(tx)
+
+	exists, err := taskExists(tx, taskId)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrTaskNotFound
+	}
+
+	// 修改任务信息
+	if update.TaskTitle != nil {
+		if _, err = tx.Exec(`UPDATE tasks SET task_title = ? WHERE task_id = ?`, *update.TaskTitle, taskId); err != nil {
+			return 0, err
+		}
+	}
+	if update.TaskDescription != nil {
+		if _, err = tx.Exec(`UPDATE tasks SET task_description = ? WHERE task_id = ?`, *update.TaskDescription, taskId); err != nil {
+			return 0, err
+		}
+	}
+	if update.Deadline != nil {
+		deadline, err := NormalizeDeadline(*update.Deadline)
+		if err != nil {
+			return 0, err
+		}
+		if _, err = tx.Exec(`UPDATE tasks SET Deadline = ? WHERE task_id = ?`, deadline, taskId); err != nil {
+			return 0, err
+		}
+	}
+	if update.AnswerRelease != nil {
+		if _, err = tx.Exec(`UPDATE tasks SET answer_release = ? WHERE task_id = ?`, *update.AnswerRelease, taskId); err != nil {
+			return 0, err
+		}
+	}
+	if update.ClassIds != nil {
+		if err = setTaskClasses(tx, taskId, *update.ClassIds); err != nil {
+			return 0, err
+		}
+	}
+	if update.AllowLate != nil {
+		if _, err = tx.Exec(`UPDATE tasks SET allow_late = ? WHERE task_id = ?`, *update.AllowLate, taskId); err != nil {
+			return 0, err
+		}
+	}
+
+	if update.MaxAttempts != nil {
+		if _, err = tx.Exec(`UPDATE tasks SET max_attempts = ? WHERE task_id = ?`, *update.MaxAttempts, taskId); err != nil {
+			return 0, err
+		}
+	}
+	if update.TimeLimit != nil {
+		if _, err = tx.Exec(`UPDATE tasks SET time_limit = ? WHERE task_id = ?`, *update.TimeLimit, taskId); err != nil {
+			return 0, err
+		}
+	}
+	policyChanged := false
+	if update.AttemptPolicy != nil {
+		result, err := tx.Exec(`UPDATE tasks SET attempt_policy = ? WHERE task_id = ? AND attempt_policy != ?`,
+			*update.AttemptPolicy, taskId, *update.AttemptPolicy)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		policyChanged = affected > 0
+	}
+
+	// 修改题目 记录答案、分值或得分规则有变化的题目
+	var changedQaIds []string
+	for _, answer := range update.Answers {
+		var qaId, qChoice, qType, qConfig string
+		var points float64
+		err = tx.QueryRow(`SELECT td.qa_id, td.q_choice, td.q_type, td.q_config, td.points FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id WHERE tqr.task_id = ? AND tqr.qa_number = ?`,
+			taskId, answer.QaNumber).Scan(&qaId, &qChoice, &qType, &qConfig, &points)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrQuestionNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		config := ParseQuestionConfig(qType, qConfig)
+		modified := (answer.QaTitle != "") || (answer.QaAnswer != "" && answer.QaAnswer != qChoice) ||
+			(answer.Points > 0 && answer.Points != points) || (answer.PartialCredit != "" && answer.PartialCredit != config.PartialCredit)
+		if modified {
+			if qaId, err = detachSharedQuestion(tx, taskId, qaId); err != nil {
+				return 0, err
+			}
+		}
+
+		if answer.QaTitle != "" {
+			if _, err = tx.Exec(`UPDATE task_data SET q_title = ? WHERE qa_id = ?`, answer.QaTitle, qaId); err != nil {
+				return 0, err
+			}
+		}
+		if answer.QaAnswer != "" && answer.QaAnswer != qChoice {
+			if err = ValidateQuestion(qType, answer.QaAnswer, ParseQuestionConfig(qType, qConfig)); err != nil {
+				return 0, fmt.Errorf("第%d题 %w", answer.QaNumber, err)
+			}
+			if _, err = tx.Exec(`UPDATE task_data SET q_choice = ? WHERE qa_id = ?`, answer.QaAnswer, qaId); err != nil {
+				return 0, err
+			}
+			changedQaIds = append(changedQaIds, qaId)
+		}
+		if answer.Points < 0 {
+			return 0, fmt.Errorf("第%d题 %w: 分值不能为负数", answer.QaNumber, ErrInvalidQuestion)
+		}
+		if answer.Points > 0 && answer.Points != points {
+			if _, err = tx.Exec(`UPDATE task_data SET points = ? WHERE qa_id = ?`, answer.Points, qaId); err != nil {
+				return 0, err
+			}
+			changedQaIds = append(changedQaIds, qaId)
+		}
+		if answer.PartialCredit != "" && answer.PartialCredit != config.PartialCredit {
+			config.PartialCredit = answer.PartialCredit
+			key := qChoice
+			if answer.QaAnswer != "" {
+				key = answer.QaAnswer
+			}
+			if err = ValidateQuestion(qType, key, config); err != nil {
+				return 0, fmt.Errorf("第%d题 %w", answer.QaNumber, err)
+			}
+			if _, err = tx.Exec(`UPDATE task_data SET q_config = ? WHERE qa_id = ?`, config.String(), qaId); err != nil {
+				return 0, err
+			}
+			changedQaIds = append(changedQaIds, qaId)
+		}
+	}
+
+	// 评分依据变化后重新评分已提交的学生 修改的题目均为本任务独有 只需重新评分本任务
+	if len(changedQaIds) > 0 || policyChanged {
+		studentIds, err := submittedStudentIds(tx, taskId)
+		if err != nil {
+			return 0, err
+		}
+		for _, studentId := range studentIds {
+			if _, err = gradeStudentTask(tx, studentId, taskId); err != nil {
+				return 0, err
+			}
+		}
+		regraded = len(studentIds)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	logger.Infof("修改任务成功 重新评分学生数: %d", regraded)
+	return regraded, nil
+}
+
+// CloseTask 关闭任务 将截止时间设为当前时间并禁止迟交
+func CloseTask(taskId string) error {
+	deadline := time.Now().Format(DeadlineLayout)
+	allowLate := false
+	_, err := UpdateTask(taskId, TaskUpdate{Deadline: &deadline, AllowLate: &allowLate})
+	return err
+}
+
+// detachSharedQuestion 题目在题库中或被其他任务引用时 复制为本任务独有的题目并返回新题目id
+// 本任务的题目关联、答题记录、草稿与错题复习记录改为指向新题目 知识点与标签一并复制
+func detachSharedQuestion(tx *sql.Tx, taskId string, qaId string) (string, error) {
+	var shared bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM task_data WHERE qa_id = ?1 AND in_bank = 1)
+		OR EXISTS (SELECT 1 FROM task_qa_relations WHERE qa_id = ?1 AND task_id != ?2)`, qaId, taskId).Scan(&shared)
+	if err != nil {
+		return "", err
+	}
+	if !shared {
+		return qaId, nil
+	}
+
+	newQaId := GenerateQaId(setting.MaxTries)
+	if newQaId == "" {
+		return "", fmt.Errorf("分配题目id失败")
+	}
+	statements := []string{
+		`INSERT INTO task_data (qa_id, q_title, qa_number, q_choice, q_type, q_config, points, create_time)
+			SELECT ?1, q_title, qa_number, q_choice, q_type, q_config, points, create_time FROM task_data WHERE qa_id = ?2`,
+		`INSERT INTO question_knowledge_points (qa_id, kp_id) SELECT ?1, kp_id FROM question_knowledge_points WHERE qa_id = ?2`,
+		`INSERT INTO question_tags (qa_id, tag) SELECT ?1, tag FROM question_tags WHERE qa_id = ?2`,
+		`UPDATE task_qa_relations SET qa_id = ?1 WHERE qa_id = ?2 AND task_id = ?3`,
+		`UPDATE student_task_answers SET qa_id = ?1 WHERE qa_id = ?2 AND task_id = ?3`,
+		`UPDATE answer_drafts SET qa_id = ?1 WHERE qa_id = ?2 AND task_id = ?3`,
+		`UPDATE wrong_question_reviews SET qa_id = ?1 WHERE qa_id = ?2 AND task_id = ?3`,
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement, newQaId, qaId, taskId); err != nil {
+			return "", err
+		}
+	}
+	return newQaId, nil
+}
+
+// submittedStudentIds 获取已提交过答案的学生
+func submittedStudentIds(ex dbExecutor, taskId string) ([]string, error) {
+	logger, _ := NewLogger()
+
+	rows, err := ex.Query(`SELECT DISTINCT student_id FROM student_task_answers WHERE task_id = ?`, taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	var studentIds []string
+	for rows.Next() {
+		var studentId string
+		if err = rows.Scan(&studentId); err != nil {
+			return nil, err
+		}
+		studentIds = append(studentIds, studentId)
+	}
+	return studentIds, rows.Err()
+}
+
+// privateQuestionsQuery 查询只被任务?1引用且不在题库中的题目
+const privateQuestionsQuery = `SELECT td.qa_id FROM task_data td INNER JOIN task_qa_relations tqr ON td.qa_id = tqr.qa_id
+	WHERE tqr.task_id = ?1 AND td.in_bank = 0 AND td.qa_id NOT IN (SELECT qa_id FROM task_qa_relations WHERE task_id != ?1)`
+
+// DeleteTask 删除任务及其题目、关联、答题与错题复习记录
+func DeleteTask(taskId string) error {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	exists, err := taskExists(tx, taskId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrTaskNotFound
+	}
+
+	statements := []string{
+		`DELETE FROM student_task_answers WHERE task_id = ?`,
+		`DELETE FROM student_task_scores WHERE task_id = ?`,
+		`DELETE FROM task_time WHERE task_id = ?`,
+		`DELETE FROM answer_drafts WHERE task_id = ?`,
+		`DELETE FROM wrong_question_reviews WHERE task_id = ?`,
+		`DELETE FROM task_classes WHERE task_id = ?`,
+		// 只删除不再被其他任务引用的题目 及其知识点与标签
+		`DELETE FROM question_knowledge_points WHERE qa_id IN (` + privateQuestionsQuery + `)`,
+		`DELETE FROM question_tags WHERE qa_id IN (` + privateQuestionsQuery + `)`,
+		`DELETE FROM task_data WHERE qa_id IN (` + privateQuestionsQuery + `)`,
+		`DELETE FROM task_qa_relations WHERE task_id = ?`,
+		`DELETE FROM tasks WHERE task_id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement, taskId); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	logger.Info("删除任务成功")
+	return nil
+}
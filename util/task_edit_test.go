@@ -0,0 +1,54 @@
+package util
+
+import "testing"
+
+// countRows 统计表中符合条件的行数
+func countRows(t *testing.T, query string, args ...interface{}) int {
+	t.Helper()
+	var count int
+	if err := db.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestDeleteTaskRemovesPrivateQuestionMetadata(t *testing.T) {
+	initTestDB(t)
+
+	kp, err := AddKnowledgePoint("第一章", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank, err := AddBankQuestion("teacher", QAAnswer{QaTitle: "bank", QaAnswer: "B", KpIds: []string{kp.KpId}}, []string{"bank-tag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers := []QAAnswer{{QaTitle: "q1", QaNumber: 1, QaAnswer: "A", KpIds: []string{kp.KpId}}}
+	if _, err = AddTask("t1", "teacher", "t1", "d", "2030-01-01 23:59:59", TaskSetting{MaxAttempts: 1}, answers, []string{bank.QaId}); err != nil {
+		t.Fatal(err)
+	}
+	var privateQaId string
+	if err = db.QueryRow(`SELECT qa_id FROM task_qa_relations WHERE task_id = 't1' AND qa_id != ?`, bank.QaId).Scan(&privateQaId); err != nil {
+		t.Fatal(err)
+	}
+	if err = setQuestionTags(db, privateQaId, []string{"private-tag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = DeleteTask("t1"); err != nil {
+		t.Fatalf("DeleteTask error: %v", err)
+	}
+
+	// 任务独有的题目连同知识点与标签一起删除
+	for _, table := range []string{"task_data", "question_knowledge_points", "question_tags"} {
+		if n := countRows(t, `SELECT COUNT(*) FROM `+table+` WHERE qa_id = ?`, privateQaId); n != 0 {
+			t.Errorf("%s 中仍有任务独有题目的 %d 行", table, n)
+		}
+	}
+	// 题库题目及其知识点与标签保留
+	for _, table := range []string{"task_data", "question_knowledge_points", "question_tags"} {
+		if n := countRows(t, `SELECT COUNT(*) FROM `+table+` WHERE qa_id = ?`, bank.QaId); n != 1 {
+			t.Errorf("%s 中题库题目有 %d 行, want 1", table, n)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package util
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ErrInvalidImportFile 导入文件无法解析
+var ErrInvalidImportFile = errors.New("导入文件格式错误")
+
+// ImportRowError 导入文件中某一行的错误 Row从1开始 包括表头行
+type ImportRowError struct {
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+	Msg    string `json:"msg"`
+}
+
+// 导入文件的列名
+const (
+	importColNumber = "qa_number"
+	importColTitle  = "title"
+	importColAnswer = "answer"
+	importColPoints = "points"
+	importColType   = "type"
+)
+
+// importColumnAliases 表头可使用的列名 不区分大小写
+var importColumnAliases = map[string]string{
+	"qa_number": importColNumber, "题号": importColNumber,
+	"title": importColTitle, "qa_title": importColTitle, "题目": importColTitle,
+	"answer": importColAnswer, "qa_answer": importColAnswer, "答案": importColAnswer,
+	"points": importColPoints, "分值": importColPoints,
+	"type": importColType, "qa_type": importColType, "题型": importColType,
+}
+
+// ParseAnswerSheet 解析CSV或XLSX格式的答案表 按文件扩展名区分格式
+// 文件本身无法解析时返回error 逐行校验的错误通过rowErrors返回
+func ParseAnswerSheet(filename string, r io.Reader) (answers []QAAnswer, rowErrors []ImportRowError, err error) {
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		records, err = reader.ReadAll()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		if len(records) > 0 && len(records[0]) > 0 {
+			records[0][0] = strings.TrimPrefix(records[0][0], utf8BOM)
+		}
+	case ".xlsx":
+		file, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		defer func() {
+			if closeErr := file.Close(); closeErr != nil {
+				logger, _ := NewLogger()
+				logger.Error(closeErr)
+			}
+		}()
+		records, err = file.GetRows(file.GetSheetName(0))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("%w: 仅支持csv或xlsx文件", ErrInvalidImportFile)
+	}
+	if len(records) < 2 {
+		return nil, nil, fmt.Errorf("%w: 文件中没有题目", ErrInvalidImportFile)
+	}
+
+	// 解析表头
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		if column, ok := importColumnAliases[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[column] = i
+		}
+	}
+	for _, column := range []string{importColNumber, importColTitle, importColAnswer} {
+		if _, ok := columns[column]; !ok {
+			return nil, nil, fmt.Errorf("%w: 缺少%s列", ErrInvalidImportFile, column)
+		}
+	}
+
+	// 逐行校验
+	seen := make(map[int]int)
+	for i, record := range records[1:] {
+		row := i + 2
+		cell := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+		if strings.Join(record, "") == "" {
+			continue // 跳过空行
+		}
+
+		rowOk := true
+		addError := func(column string, msg string) {
+			rowErrors = append(rowErrors, ImportRowError{Row: row, Column: column, Msg: msg})
+			rowOk = false
+		}
+
+		answer := QAAnswer{
+			QaTitle:  cell(importColTitle),
+			QaAnswer: cell(importColAnswer),
+			QaType:   strings.ToLower(cell(importColType)),
+		}
+		qaNumber, err := strconv.Atoi(cell(importColNumber))
+		switch {
+		case err != nil || qaNumber <= 0:
+			addError(importColNumber, "题号应为正整数")
+		default:
+			if first, duplicated := duplicateQaNumber(seen, qaNumber, row); duplicated {
+				addError(importColNumber, fmt.Sprintf("题号与第%d行重复", first))
+			}
+		}
+		answer.QaNumber = qaNumber
+		if answer.QaTitle == "" {
+			addError(importColTitle, "题目不能为空")
+		}
+		if answer.QaAnswer == "" {
+			addError(importColAnswer, "答案不能为空")
+		}
+		if points := cell(importColPoints); points != "" {
+			answer.Points, err = strconv.ParseFloat(points, 64)
+			if err != nil || answer.Points < 0 {
+				addError(importColPoints, "分值应为非负数")
+			}
+		}
+		if answer.QaType == "" {
+			answer.QaType = QuestionSingle
+		}
+		if answer.QaAnswer != "" {
+			if err = ValidateQuestion(answer.QaType, answer.QaAnswer, NewQuestionConfig(answer)); err != nil {
+				addError(importColAnswer, err.Error())
+			}
+		}
+		if rowOk {
+			answers = append(answers, answer)
+		}
+	}
+	if len(answers) == 0 && len(rowErrors) == 0 {
+		return nil, nil, fmt.Errorf("%w: 文件中没有题目", ErrInvalidImportFile)
+	}
+	return answers, rowErrors, nil
+}
@@ -0,0 +1,169 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxSheet 用给定的行生成XLSX文件内容
+func xlsxSheet(t *testing.T, records [][]string) *bytes.Buffer {
+	t.Helper()
+	file := excelize.NewFile()
+	defer file.Close()
+	sheet := file.GetSheetName(0)
+	for i, record := range records {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make([]interface{}, len(record))
+		for j, value := range record {
+			values[j] = value
+		}
+		if err = file.SetSheetRow(sheet, cell, &values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := file.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestParseAnswerSheetHeaderAliases(t *testing.T) {
+	const body = "1,q1,A,2,single\n2,q2,AC,,multiple\n"
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"英文列名", "qa_number,title,answer,points,type\n" + body},
+		{"英文别名", "QA_NUMBER,qa_title,qa_answer,Points,qa_type\n" + body},
+		{"中文列名", "题号,题目,答案,分值,题型\n" + body},
+		{"带BOM与空白", utf8BOM + " 题号 ,题目, 答案,分值,题型\n" + body},
+		{"列顺序不同", "answer,type,points,title,qa_number\nA,single,2,q1,1\nAC,multiple,,q2,2\n"},
+	}
+	for _, tt := range tests {
+		answers, rowErrors, err := ParseAnswerSheet("a.csv", strings.NewReader(tt.content))
+		if err != nil {
+			t.Errorf("%s: error: %v", tt.name, err)
+			continue
+		}
+		if len(rowErrors) != 0 {
+			t.Errorf("%s: rowErrors = %+v", tt.name, rowErrors)
+		}
+		if len(answers) != 2 {
+			t.Errorf("%s: len(answers) = %d, want 2", tt.name, len(answers))
+			continue
+		}
+		first, second := answers[0], answers[1]
+		if first.QaNumber != 1 || first.QaTitle != "q1" || first.QaAnswer != "A" || first.Points != 2 || first.QaType != QuestionSingle {
+			t.Errorf("%s: answers[0] = %+v", tt.name, first)
+		}
+		if second.QaNumber != 2 || second.QaAnswer != "AC" || second.Points != 0 || second.QaType != QuestionMultiple {
+			t.Errorf("%s: answers[1] = %+v", tt.name, second)
+		}
+	}
+}
+
+func TestParseAnswerSheetDefaults(t *testing.T) {
+	// 没有题型与分值列时按单选题处理 空行被跳过
+	answers, rowErrors, err := ParseAnswerSheet("a.CSV", strings.NewReader("题号,题目,答案\n1,q1,B\n,,\n2,q2,C\n"))
+	if err != nil || len(rowErrors) != 0 {
+		t.Fatalf("error: %v, rowErrors: %+v", err, rowErrors)
+	}
+	if len(answers) != 2 || answers[0].QaType != QuestionSingle || answers[1].QaNumber != 2 {
+		t.Errorf("answers = %+v", answers)
+	}
+}
+
+func TestParseAnswerSheetRowErrors(t *testing.T) {
+	content := strings.Join([]string{
+		"qa_number,title,answer,points,type",
+		"1,q1,A,1,single",
+		"x,q2,A,1,single",  // 第3行 题号非数字
+		"1,q3,A,1,single",  // 第4行 题号重复
+		"4,,A,1,single",    // 第5行 题目为空
+		"5,q5,,1,single",   // 第6行 答案为空
+		"6,q6,A,-1,single", // 第7行 分值为负
+		"7,q7,Z,1,single",  // 第8行 答案超出选项范围
+		"0,,,abc,single",   // 第9行 多个错误
+	}, "\n")
+	answers, rowErrors, err := ParseAnswerSheet("a.csv", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(answers) != 1 || answers[0].QaTitle != "q1" {
+		t.Errorf("answers = %+v, want only q1", answers)
+	}
+	want := []ImportRowError{
+		{Row: 3, Column: importColNumber},
+		{Row: 4, Column: importColNumber},
+		{Row: 5, Column: importColTitle},
+		{Row: 6, Column: importColAnswer},
+		{Row: 7, Column: importColPoints},
+		{Row: 8, Column: importColAnswer},
+		{Row: 9, Column: importColNumber},
+		{Row: 9, Column: importColTitle},
+		{Row: 9, Column: importColAnswer},
+		{Row: 9, Column: importColPoints},
+	}
+	if len(rowErrors) != len(want) {
+		t.Fatalf("rowErrors = %+v, want %d errors", rowErrors, len(want))
+	}
+	for i, w := range want {
+		if rowErrors[i].Row != w.Row || rowErrors[i].Column != w.Column || rowErrors[i].Msg == "" {
+			t.Errorf("rowErrors[%d] = %+v, want row %d column %s", i, rowErrors[i], w.Row, w.Column)
+		}
+	}
+	if !strings.Contains(rowErrors[1].Msg, "第2行") {
+		t.Errorf("重复题号错误未指出首次出现的行: %q", rowErrors[1].Msg)
+	}
+}
+
+func TestParseAnswerSheetInvalidFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+	}{
+		{"不支持的扩展名", "a.txt", "qa_number,title,answer\n1,q1,A\n"},
+		{"只有表头", "a.csv", "qa_number,title,answer\n"},
+		{"缺少答案列", "a.csv", "qa_number,title\n1,q1\n"},
+		{"CSV格式错误", "a.csv", "qa_number,title,answer\n1,\"q1,A\n"},
+		{"只有空行", "a.csv", "qa_number,title,answer\n,,\n"},
+		{"XLSX内容损坏", "a.xlsx", "not a zip"},
+	}
+	for _, tt := range tests {
+		_, _, err := ParseAnswerSheet(tt.filename, strings.NewReader(tt.content))
+		if !errors.Is(err, ErrInvalidImportFile) {
+			t.Errorf("%s: error = %v, want ErrInvalidImportFile", tt.name, err)
+		}
+	}
+}
+
+func TestParseAnswerSheetXLSX(t *testing.T) {
+	buf := xlsxSheet(t, [][]string{
+		{"题号", "题目", "答案", "分值", "题型"},
+		{"1", "q1", "A", "2", "single"},
+		{"2", "q2", "BD", "3", "Multiple"},
+		{"3", "", "A", "1", "single"},
+	})
+	answers, rowErrors, err := ParseAnswerSheet("a.XLSX", buf)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(answers) != 2 {
+		t.Fatalf("answers = %+v, want 2", answers)
+	}
+	if answers[1].QaNumber != 2 || answers[1].QaAnswer != "BD" || answers[1].Points != 3 || answers[1].QaType != QuestionMultiple {
+		t.Errorf("answers[1] = %+v", answers[1])
+	}
+	if len(rowErrors) != 1 || rowErrors[0].Row != 4 || rowErrors[0].Column != importColTitle {
+		t.Errorf("rowErrors = %+v, want row 4 title", rowErrors)
+	}
+}
@@ -0,0 +1,155 @@
+package util
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// TaskListQuery 任务列表查询条件
+type TaskListQuery struct {
+	// OwnerId 不为空时只返回该教师的任务
+	OwnerId       string
+	Page          int
+	PageSize      int
+	Keyword       string
+	PublishStart  string
+	PublishEnd    string
+	DeadlineStart string
+	DeadlineEnd   string
+	SortBy        string
+	Order         string
+}
+
+// TaskSummary 任务列表中的单个任务摘要
+type TaskSummary struct {
+	TaskId          string `json:"task_id"`
+	TaskTitle       string `json:"task_title"`
+	TaskDescription string `json:"task_description"`
+	PublishTime     string `json:"publish_time"`
+	Deadline        string `json:"deadline"`
+	QuestionCount   int    `json:"question_count"`
+	FetchedCount    int    `json:"fetched_count"`
+	SubmittedCount  int    `json:"submitted_count"`
+}
+
+// TaskList 任务列表分页结果
+type TaskList struct {
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Tasks    []TaskSummary `json:"tasks"`
+}
+
+// taskListSortColumns 允许排序的字段 防止拼接任意SQL
+var taskListSortColumns = map[string]string{
+	"publish_time":    "t.publish_time",
+	"deadline":        "t.Deadline",
+	"task_title":      "t.task_title",
+	"question_count":  "question_count",
+	"submitted_count": "submitted_count",
+}
+
+// likeEscaper 转义LIKE中的通配符 配合 ESCAPE '\' 使用
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern 生成按关键词模糊匹配的LIKE参数 关键词中的%和_按字面匹配
+func containsPattern(keyword string) string {
+	return "%" + likeEscaper.Replace(keyword) + "%"
+}
+
+// ListTasks 分页获取任务列表
+func ListTasks(query TaskListQuery) (*TaskList, error) {
+	logger, _ := NewLogger()
+
+	// 拼接过滤条件 学生的自学任务不在教师的任务列表中
+	conditions := []string{"t.student_id = ''"}
+	var args []interface{}
+	if query.OwnerId != "" {
+		conditions = append(conditions, "t.owner_id = ?")
+		args = append(args, query.OwnerId)
+	}
+	if query.Keyword != "" {
+		conditions = append(conditions, `t.task_title LIKE ? ESCAPE '\'`)
+		args = append(args, containsPattern(query.Keyword))
+	}
+	if query.PublishStart != "" {
+		conditions = append(conditions, "t.publish_time >= ?")
+		args = append(args, query.PublishStart)
+	}
+	if query.PublishEnd != "" {
+		conditions = append(conditions, "t.publish_time <= ?")
+		args = append(args, query.PublishEnd)
+	}
+	if query.DeadlineStart != "" {
+		conditions = append(conditions, "t.Deadline >= ?")
+		args = append(args, query.DeadlineStart)
+	}
+	if query.DeadlineEnd != "" {
+		conditions = append(conditions, "t.Deadline <= ?")
+		args = append(args, query.DeadlineEnd)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	// 排序 默认按发布时间倒序
+	sortColumn, ok := taskListSortColumns[query.SortBy]
+	if !ok {
+		sortColumn = taskListSortColumns["publish_time"]
+	}
+	order := "DESC"
+	if strings.ToLower(query.Order) == "asc" {
+		order = "ASC"
+	}
+
+	// 分页参数
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 || query.PageSize > 100 {
+		query.PageSize = 20
+	}
+
+	list := &TaskList{
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Tasks:    []TaskSummary{},
+	}
+
+	// 获取总数
+	err := db.QueryRow(`SELECT COUNT(*) FROM tasks t`+where, args...).Scan(&list.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取当前页的任务及统计数据
+	rows, err := db.Query(`SELECT t.task_id, t.task_title, t.task_description, t.publish_time, t.Deadline,
+			(SELECT COUNT(*) FROM task_qa_relations tqr WHERE tqr.task_id = t.task_id) AS question_count,
+			(SELECT COUNT(DISTINCT tt.student_id) FROM task_time tt WHERE tt.task_id = t.task_id) AS fetched_count,
+			(SELECT COUNT(DISTINCT tt.student_id) FROM task_time tt WHERE tt.task_id = t.task_id AND tt.push_answer_time != '') AS submitted_count
+		FROM tasks t`+where+` ORDER BY `+sortColumn+` `+order+`, t.task_id LIMIT ? OFFSET ?`,
+		append(args, query.PageSize, (query.Page-1)*query.PageSize)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var summary TaskSummary
+		err = rows.Scan(&summary.TaskId, &summary.TaskTitle, &summary.TaskDescription, &summary.PublishTime, &summary.Deadline,
+			&summary.QuestionCount, &summary.FetchedCount, &summary.SubmittedCount)
+		if err != nil {
+			return nil, err
+		}
+		list.Tasks = append(list.Tasks, summary)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("获取任务列表成功")
+	return list, nil
+}
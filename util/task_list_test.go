@@ -0,0 +1,20 @@
+package util
+
+import "testing"
+
+func TestContainsPattern(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    string
+	}{
+		{"期中", "%期中%"},
+		{"100%", `%100\%%`},
+		{"a_b", `%a\_b%`},
+		{`c:\tmp`, `%c:\\tmp%`},
+	}
+	for _, tt := range tests {
+		if got := containsPattern(tt.keyword); got != tt.want {
+			t.Errorf("containsPattern(%q) = %q, want %q", tt.keyword, got, tt.want)
+		}
+	}
+}
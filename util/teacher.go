@@ -0,0 +1,193 @@
+package util
+
+import (
+	"ZhiShanYunXue/setting"
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 教师端角色
+const (
+	RoleTeacher = "teacher"
+	RoleAdmin   = "admin"
+)
+
+// ErrForbidden 无权操作该资源
+var ErrForbidden = errors.New("没有操作权限")
+
+// Teacher 教师账号信息
+type Teacher struct {
+	TeacherId   string `json:"teacher_id"`
+	Username    string `json:"username"`
+	TeacherName string `json:"teacher_name"`
+	Role        string `json:"role"`
+	CreateTime  string `json:"create_time"`
+}
+
+// legacyAdminPassword 旧版本公开的默认管理员密码 仍在使用时要求修改
+const legacyAdminPassword = "admin123456"
+
+// InitAdmin 没有管理员账号时创建管理员
+// 初始密码取自环境变量 未设置时随机生成并写入仅所有者可读的文件 首次登录后需修改密码
+func InitAdmin() error {
+	logger, _ := NewLogger()
+
+	if CheckFieldValueExist("teachers", "role", RoleAdmin) {
+		return flagLegacyAdminPassword()
+	}
+
+	password := os.Getenv(setting.AdminPasswordEnv)
+	generated := password == ""
+	if generated {
+		token, err := generateToken()
+		if err != nil {
+			return err
+		}
+		password = token[:16]
+		// 先写入文件 避免创建了无人知道密码的管理员
+		if err = os.WriteFile(setting.AdminPasswordFile, []byte(password+"\n"), 0600); err != nil {
+			return err
+		}
+	}
+
+	teacher, err := AddTeacher(setting.DefaultAdminUsername, "管理员", password, RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if _, err = db.Exec(`UPDATE teachers SET must_change_password = 1 WHERE teacher_id = ?`, teacher.TeacherId); err != nil {
+		return err
+	}
+	if generated {
+		logger.Warnf("已创建管理员账号 %s 初始密码已写入 %s 首次登录后需修改密码", setting.DefaultAdminUsername, setting.AdminPasswordFile)
+	} else {
+		logger.Warnf("已创建管理员账号 %s 初始密码取自环境变量 %s 首次登录后需修改密码", setting.DefaultAdminUsername, setting.AdminPasswordEnv)
+	}
+	return nil
+}
+
+// flagLegacyAdminPassword 旧版本创建的管理员仍使用公开的默认密码时 要求登录后修改密码
+func flagLegacyAdminPassword() error {
+	logger, _ := NewLogger()
+
+	var teacherId, passwordHash string
+	err := db.QueryRow(`SELECT teacher_id, password_hash FROM teachers WHERE username = ? AND role = ? AND must_change_password = 0`,
+		setting.DefaultAdminUsername, RoleAdmin).Scan(&teacherId, &passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(legacyAdminPassword)) != nil {
+		return nil
+	}
+	if _, err = db.Exec(`UPDATE teachers SET must_change_password = 1 WHERE teacher_id = ?`, teacherId); err != nil {
+		return err
+	}
+	logger.Warnf("管理员账号 %s 仍在使用旧版本的默认密码 登录后需修改密码", setting.DefaultAdminUsername)
+	return nil
+}
+
+// AddTeacher 添加教师或管理员账号
+func AddTeacher(username string, teacherName string, password string, role string) (*Teacher, error) {
+	logger, _ := NewLogger()
+
+	if CheckFieldValueExist("teachers", "username", username) {
+		return nil, ErrUserExists
+	}
+
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	teacher := &Teacher{
+		TeacherId:   uuid.NewV4().String(),
+		Username:    username,
+		TeacherName: teacherName,
+		Role:        role,
+		CreateTime:  time.Now().Format("2006-01-02 15:04:05.000"),
+	}
+	_, err = db.Exec(`INSERT INTO teachers (teacher_id, username, teacher_name, password_hash, role, create_time) VALUES (?, ?, ?, ?, ?, ?)`,
+		teacher.TeacherId, teacher.Username, teacher.TeacherName, passwordHash, teacher.Role, teacher.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("添加教师账号成功")
+	return teacher, nil
+}
+
+// LoginTeacher 教师登录 成功后返回新的会话
+func LoginTeacher(username string, password string) (*Session, error) {
+	var teacherId, passwordHash, role string
+	var mustChangePassword bool
+	err := db.QueryRow(`SELECT teacher_id, password_hash, role, must_change_password FROM teachers WHERE username = ?`, username).
+		Scan(&teacherId, &passwordHash, &role, &mustChangePassword)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	session, err := createSession(teacherId, role)
+	if err != nil {
+		return nil, err
+	}
+	session.MustChangePassword = mustChangePassword
+	return session, nil
+}
+
+// ChangeTeacherPassword 修改教师密码 同时解除修改初始密码的要求
+func ChangeTeacherPassword(teacherId string, oldPassword string, newPassword string) error {
+	logger, _ := NewLogger()
+
+	var passwordHash string
+	err := db.QueryRow(`SELECT password_hash FROM teachers WHERE teacher_id = ?`, teacherId).Scan(&passwordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidCredentials
+	}
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(oldPassword)) != nil {
+		return ErrInvalidCredentials
+	}
+
+	newHash, err := hashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if _, err = db.Exec(`UPDATE teachers SET password_hash = ?, must_change_password = 0 WHERE teacher_id = ?`, newHash, teacherId); err != nil {
+		return err
+	}
+
+	logger.Info("修改教师密码成功")
+	return nil
+}
+
+// CheckTaskOwner 检查用户是否有权管理任务 管理员可管理所有任务
+func CheckTaskOwner(taskId string, userId string, role string) error {
+	var ownerId string
+	err := db.QueryRow(`SELECT owner_id FROM tasks WHERE task_id = ?`, taskId).Scan(&ownerId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTaskNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if role == RoleAdmin || (role == RoleTeacher && ownerId == userId) {
+		return nil
+	}
+	return ErrForbidden
+}
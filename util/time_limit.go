@@ -0,0 +1,190 @@
+package util
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrTimeLimitExceeded 超过任务的答题时限
+	ErrTimeLimitExceeded = errors.New("答题时间已到")
+	// ErrAttemptNotStarted 限时任务需先获取任务开始作答
+	ErrAttemptNotStarted = errors.New("请先获取任务开始作答")
+)
+
+// timeLimitGrace 判断超时时允许的网络延迟
+const timeLimitGrace = 5 * time.Second
+
+// taskTimeLimit 获取任务的答题时限(秒) 0表示不限时
+func taskTimeLimit(ex dbExecutor, taskId string) (int, error) {
+	var timeLimit int
+	err := ex.QueryRow(`SELECT time_limit FROM tasks WHERE task_id = ?`, taskId).Scan(&timeLimit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTaskNotFound
+	}
+	return timeLimit, err
+}
+
+// attemptExpireTime 获取作答的截止时刻 作答没有开始时间时ok为false
+func attemptExpireTime(ex dbExecutor, studentId string, taskId string, attempt int, timeLimit int) (expire time.Time, ok bool, err error) {
+	var getTaskTime string
+	err = ex.QueryRow(`SELECT get_task_time FROM task_time WHERE student_id = ? AND task_id = ? AND attempt = ?`,
+		studentId, taskId, attempt).Scan(&getTaskTime)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	start, err := time.ParseInLocation("2006-01-02 15:04:05.000", getTaskTime, time.Local)
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+	return start.Add(time.Duration(timeLimit) * time.Second), true, nil
+}
+
+// checkTimeLimit 检查限时任务是否有进行中且未超时的作答 不限时的任务直接通过
+func checkTimeLimit(ex dbExecutor, studentId string, taskId string) error {
+	timeLimit, err := taskTimeLimit(ex, taskId)
+	if err != nil || timeLimit == 0 {
+		return err
+	}
+	attempt, submitted, err := latestAttempt(ex, studentId, taskId)
+	if err != nil {
+		return err
+	}
+	if attempt == 0 || submitted {
+		return ErrAttemptNotStarted
+	}
+	expire, ok, err := attemptExpireTime(ex, studentId, taskId, attempt, timeLimit)
+	if err != nil || !ok {
+		return err
+	}
+	if time.Now().After(expire.Add(timeLimitGrace)) {
+		return ErrTimeLimitExceeded
+	}
+	return nil
+}
+
+// GetRemainingTime 获取学生当前作答的剩余时间(秒) 不限时或没有进行中的作答时返回nil
+func GetRemainingTime(studentId string, taskId string) (*int, error) {
+	timeLimit, err := taskTimeLimit(db, taskId)
+	if err != nil || timeLimit == 0 {
+		return nil, err
+	}
+	attempt, submitted, err := latestAttempt(db, studentId, taskId)
+	if err != nil || attempt == 0 || submitted {
+		return nil, err
+	}
+	expire, ok, err := attemptExpireTime(db, studentId, taskId, attempt, timeLimit)
+	if err != nil || !ok {
+		return nil, err
+	}
+	remaining := int(time.Until(expire).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &remaining, nil
+}
+
+// CloseExpiredAttempt 学生当前作答超时后以保存的草稿自动提交 提交时间记为时限到达的时刻
+// 返回是否进行了自动提交
+func CloseExpiredAttempt(studentId string, taskId string) (closed bool, err error) {
+	logger, _ := NewLogger()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer rollback(tx)
+
+	timeLimit, err := taskTimeLimit(tx, taskId)
+	if err != nil || timeLimit == 0 {
+		return false, err
+	}
+	attempt, submitted, err := latestAttempt(tx, studentId, taskId)
+	if err != nil || attempt == 0 || submitted {
+		return false, err
+	}
+	expire, ok, err := attemptExpireTime(tx, studentId, taskId, attempt, timeLimit)
+	if err != nil || !ok {
+		return false, err
+	}
+	if !time.Now().After(expire.Add(timeLimitGrace)) {
+		return false, nil
+	}
+
+	isLate, err := lateAt(tx, taskId, expire)
+	if err != nil {
+		return false, err
+	}
+	if _, err = submitAttempt(tx, studentId, taskId, attempt, nil, expire.Format("2006-01-02 15:04:05.000"), isLate); err != nil {
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	logger.Infof("作答超时自动提交 student_id: %s task_id: %s attempt: %d", studentId, taskId, attempt)
+	return true, nil
+}
+
+// SweepExpiredAttempts 自动提交所有已超时的作答 返回提交的数量
+func SweepExpiredAttempts() (int, error) {
+	logger, _ := NewLogger()
+
+	rows, err := db.Query(`SELECT DISTINCT tt.student_id, tt.task_id FROM task_time tt INNER JOIN tasks t ON t.task_id = tt.task_id
+		WHERE t.time_limit > 0 AND tt.push_answer_time = '' AND tt.get_task_time != ''`)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		StudentId string
+		TaskId    string
+	}
+	var candidates []pending
+	for rows.Next() {
+		var candidate pending
+		if err = rows.Scan(&candidate.StudentId, &candidate.TaskId); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		candidates = append(candidates, candidate)
+	}
+	if err = rows.Close(); err != nil {
+		logger.Error(err)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	closedCount := 0
+	for _, candidate := range candidates {
+		closed, err := CloseExpiredAttempt(candidate.StudentId, candidate.TaskId)
+		if err != nil {
+			// 单个作答失败不影响其他作答 下次检查时重试
+			logger.Errorf("自动提交超时作答失败 student_id: %s task_id: %s err: %v", candidate.StudentId, candidate.TaskId, err)
+			continue
+		}
+		if closed {
+			closedCount++
+		}
+	}
+	return closedCount, nil
+}
+
+// RunTimeLimitSweeper 定期自动提交超时的作答 应在单独的goroutine中运行
+func RunTimeLimitSweeper(interval time.Duration) {
+	logger, _ := NewLogger()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		closed, err := SweepExpiredAttempts()
+		if err != nil {
+			logger.Errorf("自动提交超时作答失败: %v", err)
+			continue
+		}
+		if closed > 0 {
+			logger.Infof("自动提交超时作答数: %d", closed)
+		}
+	}
+}
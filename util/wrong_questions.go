@@ -0,0 +1,253 @@
+package util
+
+import (
+	"ZhiShanYunXue/setting"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoWrongQuestions 没有符合条件的错题
+var ErrNoWrongQuestions = errors.New("没有符合条件的错题")
+
+// 错题练习任务的默认与最大题目数量
+const (
+	DefaultPracticeSize = 20
+	MaxPracticeSize     = 100
+)
+
+// practiceDuration 错题练习任务的截止期限
+const practiceDuration = 7 * 24 * time.Hour
+
+// WrongQuestion 错题本中的一道错题
+type WrongQuestion struct {
+	TaskId    string `json:"task_id"`
+	TaskTitle string `json:"task_title"`
+	QaId      string `json:"qa_id"`
+	QaNumber  int    `json:"qa_number"`
+	QaTitle   string `json:"qa_title"`
+	QaType    string `json:"q_type"`
+	// Answer 学生的答案
+	Answer string `json:"answer"`
+	// CorrectAnswer 正确答案 任务答案未公布时为空
+	CorrectAnswer  string   `json:"correct_answer"`
+	AnswerReleased bool     `json:"answer_released"`
+	Score          float64  `json:"score"`
+	Points         float64  `json:"points"`
+	Comment        string   `json:"comment"`
+	Tags           []string `json:"tags"`
+	PushAnswerTime string   `json:"push_answer_time"`
+	Reviewed       bool     `json:"reviewed"`
+	ReviewTime     string   `json:"review_time"`
+}
+
+// WrongQuestionQuery 错题本查询条件 为空的条件不参与过滤
+type WrongQuestionQuery struct {
+	StudentId string
+	TaskId    string
+	// Start、End 按提交时间筛选
+	Start string
+	End   string
+	// Tag 题目标签
+	Tag string
+	// KpId 知识点 包括其下级知识点
+	KpId string
+	// Reviewed 不为nil时按是否已复习筛选
+	Reviewed *bool
+}
+
+// ListWrongQuestions 获取学生在所有已提交任务中答错的题目 待人工评分的题目不计入
+// 多次作答时只看计入成绩的作答 重做答对的题目不再出现在错题本中
+func ListWrongQuestions(query WrongQuestionQuery) ([]WrongQuestion, error) {
+	logger, _ := NewLogger()
+
+	conditions := []string{"sta.student_id = ?", "tt.push_answer_time != ''", "sta.is_correct = 0", "sta.needs_grading = 0"}
+	args := []interface{}{query.StudentId}
+	if query.TaskId != "" {
+		conditions = append(conditions, "sta.task_id = ?")
+		args = append(args, query.TaskId)
+	}
+	if query.Start != "" {
+		conditions = append(conditions, "tt.push_answer_time >= ?")
+		args = append(args, query.Start)
+	}
+	if query.End != "" {
+		conditions = append(conditions, "tt.push_answer_time <= ?")
+		args = append(args, query.End)
+	}
+	if query.Tag != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM question_tags qt WHERE qt.qa_id = sta.qa_id AND qt.tag = ?)")
+		args = append(args, query.Tag)
+	}
+	if query.KpId != "" {
+		conditions = append(conditions, `EXISTS (WITH RECURSIVE kps(kp_id) AS (
+				SELECT ? UNION SELECT kp.kp_id FROM knowledge_points kp INNER JOIN kps ON kp.parent_id = kps.kp_id
+			) SELECT 1 FROM question_knowledge_points qkp INNER JOIN kps ON kps.kp_id = qkp.kp_id WHERE qkp.qa_id = sta.qa_id)`)
+		args = append(args, query.KpId)
+	}
+	if query.Reviewed != nil {
+		if *query.Reviewed {
+			conditions = append(conditions, "wr.review_time IS NOT NULL")
+		} else {
+			conditions = append(conditions, "wr.review_time IS NULL")
+		}
+	}
+
+	rows, err := db.Query(`SELECT sta.task_id, t.task_title, sta.qa_id, tqr.qa_number, COALESCE(td.q_title, ''), td.q_type,
+			sta.answer, td.q_choice, sta.score, td.points, sta.comment, tt.push_answer_time, COALESCE(wr.review_time, '')
+		FROM student_task_answers sta
+		INNER JOIN student_task_scores sts ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+		INNER JOIN task_time tt ON tt.student_id = sta.student_id AND tt.task_id = sta.task_id AND tt.attempt = sta.attempt
+		INNER JOIN tasks t ON t.task_id = sta.task_id
+		INNER JOIN task_data td ON td.qa_id = sta.qa_id
+		INNER JOIN task_qa_relations tqr ON tqr.task_id = sta.task_id AND tqr.qa_id = sta.qa_id
+		LEFT JOIN wrong_question_reviews wr ON wr.student_id = sta.student_id AND wr.task_id = sta.task_id AND wr.qa_id = sta.qa_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY tt.push_answer_time DESC, sta.task_id, tqr.qa_number`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		closeErr := rows.Close()
+		if closeErr != nil {
+			logger.Error(closeErr)
+		}
+	}(rows)
+
+	questions := []WrongQuestion{}
+	for rows.Next() {
+		var question WrongQuestion
+		err = rows.Scan(&question.TaskId, &question.TaskTitle, &question.QaId, &question.QaNumber, &question.QaTitle, &question.QaType,
+			&question.Answer, &question.CorrectAnswer, &question.Score, &question.Points, &question.Comment,
+			&question.PushAnswerTime, &question.ReviewTime)
+		if err != nil {
+			return nil, err
+		}
+		question.Reviewed = question.ReviewTime != ""
+		questions = append(questions, question)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 按任务的答案公布策略隐藏正确答案 并补充标签
+	released := make(map[string]bool)
+	for i := range questions {
+		question := &questions[i]
+		isReleased, ok := released[question.TaskId]
+		if !ok {
+			isReleased, err = IsAnswerReleased(question.TaskId, query.StudentId)
+			if err != nil {
+				return nil, err
+			}
+			released[question.TaskId] = isReleased
+		}
+		question.AnswerReleased = isReleased
+		if !isReleased {
+			question.CorrectAnswer = ""
+		}
+		question.Tags, err = GetQuestionTags(question.QaId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Info("获取错题本成功")
+	return questions, nil
+}
+
+// MarkWrongQuestionReviewed 标记或取消标记错题已复习
+func MarkWrongQuestionReviewed(studentId string, taskId string, qaId string, reviewed bool) error {
+	logger, _ := NewLogger()
+
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM student_task_answers sta
+		INNER JOIN student_task_scores sts ON sts.student_id = sta.student_id AND sts.task_id = sta.task_id AND sts.attempt = sta.attempt
+		WHERE sta.student_id = ? AND sta.task_id = ? AND sta.qa_id = ? AND sta.is_correct = 0`,
+		studentId, taskId, qaId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrAnswerNotFound
+	}
+
+	if reviewed {
+		_, err = db.Exec(`INSERT OR IGNORE INTO wrong_question_reviews (student_id, task_id, qa_id, review_time) VALUES (?, ?, ?, ?)`,
+			studentId, taskId, qaId, time.Now().Format("2006-01-02 15:04:05.000"))
+	} else {
+		_, err = db.Exec(`DELETE FROM wrong_question_reviews WHERE student_id = ? AND task_id = ? AND qa_id = ?`, studentId, taskId, qaId)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("更新错题复习状态成功")
+	return nil
+}
+
+// CreatePracticeTask 用符合条件的错题生成只对该学生开放的自学任务 同一题目只出现一次
+// 原任务答案尚未公布的题目不会选入 避免通过练习提前看到答案
+// 自学任务的owner_id为空 不属于任何教师 不出现在任务列表中 只有管理员能按任务id修改或删除
+func CreatePracticeTask(query WrongQuestionQuery, size int) (taskId string, questionCount int, err error) {
+	logger, _ := NewLogger()
+
+	if size < 1 || size > MaxPracticeSize {
+		size = DefaultPracticeSize
+	}
+	questions, err := ListWrongQuestions(query)
+	if err != nil {
+		return "", 0, err
+	}
+	seen := make(map[string]bool)
+	var qaIds []string
+	for _, question := range questions {
+		if seen[question.QaId] || !question.AnswerReleased {
+			continue
+		}
+		seen[question.QaId] = true
+		qaIds = append(qaIds, question.QaId)
+		if len(qaIds) == size {
+			break
+		}
+	}
+	if len(qaIds) == 0 {
+		return "", 0, ErrNoWrongQuestions
+	}
+
+	taskId = GenerateTaskId(setting.MaxTries)
+	if taskId == "" {
+		return "", 0, fmt.Errorf("分配任务id失败")
+	}
+	now := time.Now()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	defer rollback(tx)
+
+	// 自学任务允许迟交且不限提交次数
+	_, err = tx.Exec(`INSERT INTO tasks (task_id, task_title, task_description, publish_time, Deadline, allow_late, answer_release, max_attempts, student_id)
+		VALUES (?, ?, ?, ?, ?, 1, ?, 0, ?)`,
+		taskId, "错题练习 "+now.Format("2006-01-02"), fmt.Sprintf("根据错题本生成的自学任务 共%d题", len(qaIds)),
+		now.Format("2006-01-02 15:04:05.000"), now.Add(practiceDuration).Format(DeadlineLayout), ReleaseAfterSubmit, query.StudentId)
+	if err != nil {
+		return "", 0, err
+	}
+	for i, qaId := range qaIds {
+		_, err = tx.Exec(`INSERT INTO task_qa_relations (task_id, qa_id, qa_number) VALUES (?, ?, ?)`, taskId, qaId, i+1)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", 0, err
+	}
+
+	logger.Info("生成错题练习成功")
+	return taskId, len(qaIds), nil
+}